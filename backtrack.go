@@ -0,0 +1,64 @@
+package kenken
+
+import (
+	"context"
+	"time"
+)
+
+// Backtrack is the default Solver. It searches depth first, filling the box
+// with the fewest candidates next, and propagates the row, column and region
+// constraints at each node.
+type Backtrack struct {
+	Options SolveOptions
+}
+
+func (b Backtrack) Solve(ctx context.Context, p *Puzzle) (Result, error) {
+	result := Result{Solver: "backtrack"}
+	if err := p.Validate(); err != nil {
+		return result, err
+	}
+	start := time.Now()
+	p.run = &solveRun{ctx: ctx, opts: b.Options}
+	defer func() { p.run = nil }()
+	err := p.trySolve()
+	p.run.stats.Duration = time.Since(start)
+	if stopped, ok := err.(StoppedError); ok {
+		stopped.Stats = p.run.stats
+		err = stopped
+	}
+	result.Stats = p.run.stats
+	return result, err
+}
+
+func (b Backtrack) Count(ctx context.Context, p *Puzzle, limit int) (int, error) {
+	if err := p.Validate(); err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	c := p.Clone()
+	c.run = &solveRun{ctx: ctx, opts: b.Options}
+	count := 0
+	c.search(func() bool {
+		count++
+		return limit > 0 && count >= limit
+	})
+	if c.run.err != nil {
+		c.run.stats.Duration = time.Since(start)
+		return count, StoppedError{c.run.err, c.run.stats}
+	}
+	return count, nil
+}
+
+func (p *Puzzle) trySolve() error {
+	found, numFailedPaths := p.search(func() bool { return true })
+	if p.run != nil {
+		p.run.stats.FailedPaths = numFailedPaths
+		if p.run.err != nil {
+			return StoppedError{p.run.err, p.run.stats}
+		}
+	}
+	if !found {
+		return UnsolveableError{numFailedPaths}
+	}
+	return nil
+}
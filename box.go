@@ -1,8 +1,17 @@
 package kenken
 
-import "fmt"
+import (
+	"fmt"
+	"iter"
+	"math/bits"
+)
 
-type PossibleSet map[uint8]struct{}
+// MaxSize is the largest puzzle size, limited by the width of PossibleSet.
+const MaxSize = 63
+
+// PossibleSet is a set of values from 0 to MaxSize, with bit v set if v is in
+// the set.
+type PossibleSet uint64
 
 type Box struct {
 	idx       Index
@@ -19,10 +28,10 @@ func (b *Box) UnsetValue() { b.value = 0 }
 
 func (b Box) IsValueSet() bool { return b.value != 0 }
 
-func (b Box) NumPossible() uint8 { return uint8(len(b.possibles)) }
+func (b Box) NumPossible() uint8 { return uint8(b.possibles.Len()) }
 
 func (b *Box) DeletePossible(p uint8) {
-	delete(b.possibles, p)
+	b.possibles.Delete(p)
 }
 
 func (b *Box) AddPossible(p uint8) {
@@ -33,20 +42,18 @@ func (b Box) HasPossible(p uint8) bool {
 	return b.possibles.Contains(p)
 }
 
+// GetPossibles returns the possible values in increasing order.
 func (b Box) GetPossibles() []byte {
-	p := make([]byte, b.NumPossible())
-	i := 0
-	for k := range b.possibles {
-		p[i] = k
-		i++
-	}
-	return p
+	return b.possibles.Values()
 }
 
+// Possibles returns the set of possible values, without allocating.
+func (b Box) Possibles() PossibleSet { return b.possibles }
+
 func NewBox(idx Index, size uint8) *Box {
 	box := new(Box)
 	box.idx = idx
-	box.possibles = make(PossibleSet)
+	box.possibles = 0
 	box.value = 0
 	box.heapIndex = -1
 	return box
@@ -59,12 +66,59 @@ func (b Box) ValueString() string {
 	return " "
 }
 
+// Add adds x to the set. It panics if x is larger than MaxSize.
 func (ps *PossibleSet) Add(x uint8) {
-	var empty struct{}
-	(*ps)[x] = empty
+	if x > MaxSize {
+		panic(fmt.Sprintf("kenken: value %v is larger than MaxSize", x))
+	}
+	*ps |= 1 << x
+}
+
+func (ps *PossibleSet) Delete(x uint8) {
+	*ps &^= 1 << x
+}
+
+func (ps PossibleSet) Contains(x uint8) bool {
+	return x <= MaxSize && ps&(1<<x) != 0
+}
+
+func (ps PossibleSet) Len() int {
+	return bits.OnesCount64(uint64(ps))
+}
+
+// Min returns the smallest value in the set. The set must not be empty.
+func (ps PossibleSet) Min() uint8 {
+	return uint8(bits.TrailingZeros64(uint64(ps)))
+}
+
+func (ps PossibleSet) Union(o PossibleSet) PossibleSet { return ps | o }
+
+func (ps PossibleSet) Intersect(o PossibleSet) PossibleSet { return ps & o }
+
+func (ps PossibleSet) Difference(o PossibleSet) PossibleSet { return ps &^ o }
+
+// All iterates over the values in increasing order. Changes to the set while
+// iterating don't affect the values seen.
+func (ps PossibleSet) All() iter.Seq[uint8] {
+	return func(yield func(uint8) bool) {
+		for ; ps != 0; ps &= ps - 1 {
+			if !yield(ps.Min()) {
+				return
+			}
+		}
+	}
+}
+
+// Values returns the values in increasing order.
+func (ps PossibleSet) Values() []uint8 {
+	values := make([]uint8, 0, ps.Len())
+	for v := range ps.All() {
+		values = append(values, v)
+	}
+	return values
 }
 
-func (ps *PossibleSet) Contains(x uint8) bool {
-	_, present := (*ps)[x]
-	return present
+// FullSet returns the set of values from 1 to size.
+func FullSet(size uint8) PossibleSet {
+	return PossibleSet(uint64(1)<<(size+1)-1) &^ 1
 }
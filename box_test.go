@@ -77,3 +77,46 @@ func TestBoxGetPossibles(t *testing.T) {
 		}
 	}
 }
+
+func TestPossibleSet(t *testing.T) {
+	s := PossibleSet(0)
+	s.Add(5)
+	s.Add(1)
+	s.Add(MaxSize)
+	s.Add(5)
+	if s.Len() != 3 || !s.Contains(1) || !s.Contains(MaxSize) || s.Contains(2) || s.Contains(MaxSize+1) {
+		t.Errorf("Set had the wrong values: %v", s.Values())
+	}
+	if s.Min() != 1 {
+		t.Errorf("Min was %v, expected 1", s.Min())
+	}
+	values := make([]uint8, 0)
+	for v := range s.All() {
+		values = append(values, v)
+		s.Delete(v)
+	}
+	if len(values) != 3 || values[0] != 1 || values[1] != 5 || values[2] != MaxSize || s.Len() != 0 {
+		t.Errorf("Iterated over %v, leaving %v", values, s.Values())
+	}
+
+	full := FullSet(4)
+	if full.Len() != 4 || full.Contains(0) || !full.Contains(4) || full.Contains(5) {
+		t.Errorf("Full set of 4 was %v", full.Values())
+	}
+	other := PossibleSet(0)
+	other.Add(4)
+	other.Add(7)
+	if full.Union(other).Len() != 5 || full.Intersect(other).Len() != 1 || full.Difference(other).Contains(4) {
+		t.Errorf("Set algebra gave the wrong results")
+	}
+}
+
+func TestPossibleSetAddTooLarge(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Adding a value above MaxSize did not panic")
+		}
+	}()
+	s := PossibleSet(0)
+	s.Add(MaxSize + 1)
+}
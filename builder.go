@@ -0,0 +1,43 @@
+package kenken
+
+import "fmt"
+
+// PuzzleBuilder assembles a Puzzle from its cages without going through the
+// interactive RequestPuzzle prompt.
+type PuzzleBuilder struct {
+	size    uint8
+	regions []Region
+}
+
+func NewPuzzleBuilder(size uint8) *PuzzleBuilder {
+	return &PuzzleBuilder{size, nil}
+}
+
+// AddCage adds a region covering cells whose values combine under op to give
+// result. Calls can be chained.
+func (b *PuzzleBuilder) AddCage(op Operation, result uint, cells ...Index) *PuzzleBuilder {
+	b.regions = append(b.regions, *NewRegion(op, result, cells...))
+	return b
+}
+
+// Build creates the puzzle and prepares it for solving. If the cages have any
+// structural defects, the returned error is a ValidationError. The builder may
+// be reused afterwards; the returned puzzle does not share state with it.
+func (b *PuzzleBuilder) Build() (*Puzzle, error) {
+	if b.size == 0 {
+		return nil, fmt.Errorf("puzzle size must be at least 1")
+	}
+	if b.size > MaxSize {
+		return nil, fmt.Errorf("puzzle size must be at most %v", MaxSize)
+	}
+	p := NewPuzzle(b.size)
+	p.regions = make([]Region, len(b.regions))
+	for i, r := range b.regions {
+		p.regions[i] = *NewRegion(r.op, r.result, r.GetIndices()...)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	p.prepare()
+	return p, nil
+}
@@ -0,0 +1,68 @@
+package kenken
+
+import "testing"
+
+func TestBuilderSolve(t *testing.T) {
+	p, err := examplePuzzleBuilder().Build()
+	if err != nil {
+		t.Fatalf("Build failed with error: %v", err)
+	}
+	if err = p.Solve(); err != nil {
+		t.Fatalf("Solve failed with error: %v", err)
+	}
+	s := exampleSolution()
+	for y := uint8(0); y < p.Size(); y++ {
+		for x := uint8(0); x < p.Size(); x++ {
+			if v := p.GetValue(Index{x, y}); v != s[y][x] {
+				t.Errorf("Value at %v was %v, expected %v", Index{x, y}, v, s[y][x])
+			}
+		}
+	}
+}
+
+func TestBuilderIsReusable(t *testing.T) {
+	b := examplePuzzleBuilder()
+	p1, _ := b.Build()
+	p2, _ := b.Build()
+	if err := p1.Solve(); err != nil {
+		t.Fatalf("Solve failed with error: %v", err)
+	}
+	if p2.GetValue(Index{0, 0}) != 0 {
+		t.Errorf("Solving one puzzle modified another built by the same builder")
+	}
+	if len(p1.GetRegions()) != 12 {
+		t.Errorf("Puzzle had %v regions, expected %v", len(p1.GetRegions()), 12)
+	}
+}
+
+func TestBuilderRejectsOutOfBounds(t *testing.T) {
+	_, err := NewPuzzleBuilder(2).AddCage(Nothing, 1, Index{2, 0}).Build()
+	if err == nil {
+		t.Errorf("Build accepted a cell outside of the puzzle")
+	}
+}
+
+// examplePuzzleBuilder describes the same puzzle as examplePuzzle.
+func examplePuzzleBuilder() *PuzzleBuilder {
+	return NewPuzzleBuilder(5).
+		AddCage(Mul, 12, Index{0, 4}, Index{1, 4}, Index{2, 4}).
+		AddCage(Div, 2, Index{3, 4}, Index{3, 3}).
+		AddCage(Sum, 10, Index{4, 4}, Index{4, 3}, Index{4, 2}).
+		AddCage(Sub, 1, Index{0, 3}, Index{1, 3}).
+		AddCage(Nothing, 3, Index{2, 3}).
+		AddCage(Nothing, 2, Index{0, 2}).
+		AddCage(Mul, 20, Index{0, 1}, Index{1, 1}, Index{1, 2}).
+		AddCage(Sum, 3, Index{2, 2}, Index{2, 1}).
+		AddCage(Sub, 2, Index{3, 2}, Index{3, 1}).
+		AddCage(Sub, 3, Index{4, 1}, Index{4, 0}).
+		AddCage(Sub, 1, Index{0, 0}, Index{1, 0}).
+		AddCage(Sum, 9, Index{2, 0}, Index{3, 0})
+}
+
+func TestBuilderRejectsBadSize(t *testing.T) {
+	for _, size := range []uint8{0, MaxSize + 1} {
+		if _, err := NewPuzzleBuilder(size).Build(); err == nil {
+			t.Errorf("Built a puzzle of size %v", size)
+		}
+	}
+}
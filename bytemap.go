@@ -1,60 +1,83 @@
 package kenken
 
-import "sort"
+import "fmt"
 
+// ByteMap is a multiset of values from 0 to MaxSize, stored as a count for
+// each value. It's comparable, and copying it doesn't allocate.
 type ByteMap struct {
-	m    map[byte]int
-	size int
+	counts [MaxSize + 1]uint8
+	size   int
 }
 
 func NewByteMap() *ByteMap {
-	return &ByteMap{make(map[byte]int), 0}
+	return &ByteMap{}
 }
 
+// Add adds one more i to the multiset. It panics if i is larger than MaxSize.
 func (m *ByteMap) Add(i byte) {
-	n, _ := (*m).m[i]
-	(*m).m[i] = n + 1
-	(*m).size++
+	if i > MaxSize {
+		panic(fmt.Sprintf("kenken: value %v is larger than MaxSize", i))
+	}
+	m.counts[i]++
+	m.size++
+}
+
+// Count returns the number of times i is in the multiset.
+func (m *ByteMap) Count(i byte) int {
+	if int(i) >= len(m.counts) {
+		return 0
+	}
+	return int(m.counts[i])
 }
 
+// Map returns the multiset as a map from each value to its count.
 func (m *ByteMap) Map() map[byte]int {
-	return m.m
+	c := make(map[byte]int)
+	for v, n := range m.counts {
+		if n > 0 {
+			c[byte(v)] = int(n)
+		}
+	}
+	return c
+}
+
+// Values returns the distinct values in the multiset.
+func (m *ByteMap) Values() PossibleSet {
+	s := PossibleSet(0)
+	for v, n := range m.counts {
+		if n > 0 {
+			s.Add(uint8(v))
+		}
+	}
+	return s
 }
 
 func (m *ByteMap) Len() int {
-	return (*m).size
+	return m.size
 }
 
 func (m *ByteMap) Copy() ByteMap {
-	c := *NewByteMap()
-	for k, v := range (*m).m {
-		c.m[k] = v
-	}
-	c.size = (*m).size
-	return c
+	return *m
 }
 
 func (m *ByteMap) GetSortedList() []byte {
-	list := make([]byte, m.Len())
-	i := 0
-	for k, v := range (*m).m {
-		for ; v > 0; v-- {
-			list[i] = k
-			i++
+	list := make([]byte, 0, m.size)
+	for v, n := range m.counts {
+		for ; n > 0; n-- {
+			list = append(list, byte(v))
 		}
 	}
-	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
 	return list
 }
 
 func (m *ByteMap) Equals(o *ByteMap) bool {
-	if m.Len() != o.Len() {
-		return false
-	}
-	list := m.GetSortedList()
-	otherList := o.GetSortedList()
-	for i, v := range list {
-		if v != otherList[i] {
+	return m.counts == o.counts
+}
+
+// Includes reports whether every value of o is in m at least as many times.
+func (m *ByteMap) Includes(o *ByteMap) bool {
+	for v, n := range o.counts {
+		if n > m.counts[v] {
 			return false
 		}
 	}
@@ -20,6 +20,15 @@ func TestByteMapAddGet(t *testing.T) {
 	}
 }
 
+func TestByteMapAddTooLarge(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Adding a value above MaxSize did not panic")
+		}
+	}()
+	NewByteMap().Add(MaxSize + 1)
+}
+
 func TestByteMapLen(t *testing.T) {
 	m := NewByteMap()
 	if m.Len() != 0 {
@@ -61,3 +70,23 @@ func TestByteMapEquals(t *testing.T) {
 		t.Fatal("Arrays should not be equal")
 	}
 }
+
+func TestByteMapIncludes(t *testing.T) {
+	m := *NewByteMap()
+	m.Add(2)
+	m.Add(2)
+	m.Add(5)
+	n := *NewByteMap()
+	n.Add(2)
+	if !m.Includes(&n) || n.Includes(&m) {
+		t.Errorf("Expected %v to include %v but not the reverse", m.GetSortedList(), n.GetSortedList())
+	}
+	n.Add(2)
+	n.Add(2)
+	if m.Includes(&n) {
+		t.Errorf("%v should not include %v", m.GetSortedList(), n.GetSortedList())
+	}
+	if m.Count(2) != 2 || m.Count(3) != 0 || m.Values().Len() != 2 {
+		t.Errorf("Wrong counts for %v", m.GetSortedList())
+	}
+}
@@ -0,0 +1,387 @@
+// Command kenken solves, checks and converts Kenken puzzles.
+//
+// Usage:
+//
+//	kenken <command> [flags] [file ...]
+//
+// Each command reads the named puzzle files, or standard input if there are
+// none or a file is "-". Puzzles may be in the text format, JSON or a Keen
+// game ID; by default the format is detected from the contents.
+//
+// Exit codes are 0 on success, 1 if a puzzle is invalid or has no solution
+// (or, for count, not exactly one), and 2 for usage, input or output errors.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	kenken "github.com/MorganR/KenkenSolver"
+)
+
+const (
+	exitOK      = 0
+	exitPuzzle  = 1
+	exitFailure = 2
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"solve", "solve puzzles and print their solutions", runSolve},
+		{"validate", "check puzzles for structural defects", runValidate},
+		{"render", "draw puzzles, or convert them to another format", runRender},
+		{"enter", "enter a puzzle interactively and write it out", runEnter},
+		{"count", "count the solutions of puzzles", runCount},
+		{"grade", "rate the difficulty of puzzles", runGrade},
+		{"decode", "fill puzzles from a SAT or SMT solver's model", runDecode},
+	}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 {
+		usage()
+		return exitFailure
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:])
+		}
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage()
+		return exitOK
+	}
+	fmt.Fprintf(os.Stderr, "kenken: unknown command %q\n", args[0])
+	usage()
+	return exitFailure
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: kenken <command> [flags] [file ...]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-9v %v\n", c.name, c.summary)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun 'kenken <command> -h' for the flags of each command.")
+}
+
+// newFlagSet creates the flags shared by every command that reads puzzles.
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	format := fs.String("format", "auto", "input format: auto, text, json or keen")
+	return fs, format
+}
+
+func runSolve(args []string) int {
+	fs, format := newFlagSet("solve")
+	to := fs.String("to", "grid", "output format: grid or json")
+	stats := fs.Bool("stats", false, "print search statistics to standard error")
+	name := fs.String("solver", "backtrack", "solving strategy: "+strings.Join(kenken.SolverNames(), ", "))
+	parallel := fs.Bool("parallel", false, "search on every CPU; short for -solver parallel")
+	if err := fs.Parse(args); err != nil {
+		return exitFailure
+	}
+	if *to != "grid" && *to != "json" {
+		fmt.Fprintf(os.Stderr, "kenken: unknown output format %q\n", *to)
+		return exitFailure
+	}
+	if err := useParallel(fs, name, *parallel); err != nil {
+		fmt.Fprintf(os.Stderr, "kenken: %v\n", err)
+		return exitFailure
+	}
+	solver, err := kenken.GetSolver(*name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kenken: %v\n", err)
+		return exitFailure
+	}
+	return forEachPuzzle(fs.Args(), *format, func(name string, p *kenken.Puzzle) int {
+		result, err := solver.Solve(context.Background(), p)
+		if *stats {
+			fmt.Fprintf(os.Stderr, "%v: %v: %+v\n", name, result.Solver, result.Stats)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "kenken: %v: %v\n", name, err)
+			return exitPuzzle
+		}
+		if *to == "json" {
+			return writeJSON(p)
+		}
+		fmt.Print(p.String())
+		return exitOK
+	})
+}
+
+// useParallel sets name to "parallel" if -parallel was given, unless -solver
+// names a different solver.
+func useParallel(fs *flag.FlagSet, name *string, parallel bool) error {
+	if !parallel {
+		return nil
+	}
+	explicit := false
+	fs.Visit(func(f *flag.Flag) { explicit = explicit || f.Name == "solver" })
+	if explicit && *name != "parallel" {
+		return fmt.Errorf("-parallel can't be used with -solver %v", *name)
+	}
+	*name = "parallel"
+	return nil
+}
+
+func runValidate(args []string) int {
+	fs, format := newFlagSet("validate")
+	if err := fs.Parse(args); err != nil {
+		return exitFailure
+	}
+	return forEachPuzzle(fs.Args(), *format, func(name string, p *kenken.Puzzle) int {
+		fmt.Printf("%v: ok\n", name)
+		return exitOK
+	})
+}
+
+func runRender(args []string) int {
+	fs, format := newFlagSet("render")
+	to := fs.String("to", "grid", "output format: grid, text, json, keen, dimacs, smt, mzn or dzn")
+	if err := fs.Parse(args); err != nil {
+		return exitFailure
+	}
+	return forEachPuzzle(fs.Args(), *format, func(name string, p *kenken.Puzzle) int {
+		return writePuzzle(p, *to)
+	})
+}
+
+func runEnter(args []string) int {
+	fs := flag.NewFlagSet("enter", flag.ContinueOnError)
+	size := fs.Uint("size", 0, "width of the puzzle")
+	to := fs.String("to", "text", "output format: grid, text, json or keen")
+	if err := fs.Parse(args); err != nil {
+		return exitFailure
+	}
+	if *size < 1 || *size > kenken.MaxSize || fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "usage: kenken enter -size n [-to format]")
+		return exitFailure
+	}
+	p := kenken.RequestPuzzle(uint8(*size))
+	if err := p.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "kenken: %v\n", err)
+		return exitPuzzle
+	}
+	return writePuzzle(p, *to)
+}
+
+func runCount(args []string) int {
+	fs, format := newFlagSet("count")
+	limit := fs.Int("limit", 0, "stop counting after this many solutions, or 0 for no limit")
+	name := fs.String("solver", "backtrack", "counting strategy: "+strings.Join(kenken.SolverNames(), ", "))
+	parallel := fs.Bool("parallel", false, "search on every CPU; short for -solver parallel")
+	if err := fs.Parse(args); err != nil {
+		return exitFailure
+	}
+	if err := useParallel(fs, name, *parallel); err != nil {
+		fmt.Fprintf(os.Stderr, "kenken: %v\n", err)
+		return exitFailure
+	}
+	solver, err := kenken.GetSolver(*name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kenken: %v\n", err)
+		return exitFailure
+	}
+	counter, ok := solver.(kenken.Counter)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "kenken: solver %q can't count solutions\n", *name)
+		return exitFailure
+	}
+	return forEachPuzzle(fs.Args(), *format, func(name string, p *kenken.Puzzle) int {
+		count, err := counter.Count(context.Background(), p, *limit)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "kenken: %v: %v\n", name, err)
+			return exitPuzzle
+		}
+		if *limit > 0 && count >= *limit {
+			fmt.Printf("%v: at least %v solutions\n", name, count)
+		} else {
+			fmt.Printf("%v: %v solution(s)\n", name, count)
+		}
+		if count != 1 {
+			return exitPuzzle
+		}
+		return exitOK
+	})
+}
+
+func runGrade(args []string) int {
+	fs, format := newFlagSet("grade")
+	if err := fs.Parse(args); err != nil {
+		return exitFailure
+	}
+	return forEachPuzzle(fs.Args(), *format, func(name string, p *kenken.Puzzle) int {
+		d := kenken.Grade(p)
+		fmt.Printf("%v: %v\n", name, d)
+		if d.Tier == kenken.Unsolvable {
+			return exitPuzzle
+		}
+		return exitOK
+	})
+}
+
+func runDecode(args []string) int {
+	fs, format := newFlagSet("decode")
+	model := fs.String("model", "", "file holding the solver's output for the puzzle's formula")
+	from := fs.String("from", "dimacs", "model format: dimacs or smt")
+	to := fs.String("to", "grid", "output format: grid or json")
+	if err := fs.Parse(args); err != nil {
+		return exitFailure
+	}
+	read, known := map[string]func(io.Reader, *kenken.Puzzle) error{
+		"dimacs": kenken.ReadDIMACSModel,
+		"smt":    kenken.ReadSMTModel,
+	}[*from]
+	if *model == "" || !known || (*to != "grid" && *to != "json") {
+		fmt.Fprintln(os.Stderr, "usage: kenken decode -model file [-from dimacs|smt] [-to grid|json] [file]")
+		return exitFailure
+	}
+	return forEachPuzzle(fs.Args(), *format, func(name string, p *kenken.Puzzle) int {
+		f, err := os.Open(*model)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "kenken: %v\n", err)
+			return exitFailure
+		}
+		defer f.Close()
+		if err := read(f, p); err != nil {
+			fmt.Fprintf(os.Stderr, "kenken: %v: %v\n", name, err)
+			return exitPuzzle
+		}
+		if *to == "json" {
+			return writeJSON(p)
+		}
+		fmt.Print(p.String())
+		return exitOK
+	})
+}
+
+// forEachPuzzle reads each file and calls fn with the puzzle it holds. It
+// returns the highest exit code seen.
+func forEachPuzzle(files []string, format string, fn func(name string, p *kenken.Puzzle) int) int {
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	code := exitOK
+	for _, file := range files {
+		name := file
+		var data []byte
+		var err error
+		if file == "-" {
+			name = "<stdin>"
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(file)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "kenken: %v\n", err)
+			code = exitFailure
+			continue
+		}
+		p, err := readPuzzle(data, format)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "kenken: %v: %v\n", name, err)
+			var verr kenken.ValidationError
+			if errors.As(err, &verr) {
+				code = max(code, exitPuzzle)
+			} else {
+				code = exitFailure
+			}
+			continue
+		}
+		code = max(code, fn(name, p))
+	}
+	return code
+}
+
+var keenID = regexp.MustCompile(`^\d+[a-z]*:`)
+
+func readPuzzle(data []byte, format string) (*kenken.Puzzle, error) {
+	if format == "auto" {
+		trimmed := bytes.TrimSpace(data)
+		switch {
+		case bytes.HasPrefix(trimmed, []byte("{")):
+			format = "json"
+		case keenID.Match(trimmed):
+			format = "keen"
+		default:
+			format = "text"
+		}
+	}
+	switch format {
+	case "text":
+		return kenken.Parse(bytes.NewReader(data))
+	case "json":
+		p := new(kenken.Puzzle)
+		if err := json.Unmarshal(data, p); err != nil {
+			return nil, err
+		}
+		return p, nil
+	case "keen":
+		return kenken.ParseKeen(strings.TrimSpace(string(data)))
+	}
+	return nil, fmt.Errorf("unknown input format %q", format)
+}
+
+func writePuzzle(p *kenken.Puzzle, format string) int {
+	var err error
+	switch format {
+	case "grid":
+		fmt.Print(p.String())
+	case "text":
+		err = kenken.Write(os.Stdout, p)
+	case "json":
+		return writeJSON(p)
+	case "keen":
+		var id string
+		if id, err = kenken.FormatKeen(p); err == nil {
+			fmt.Println(id)
+		}
+	case "dimacs":
+		err = kenken.WriteDIMACS(os.Stdout, p)
+	case "smt":
+		err = kenken.WriteSMTLIB(os.Stdout, p)
+	case "mzn":
+		err = kenken.WriteMiniZinc(os.Stdout, io.Discard, p)
+	case "dzn":
+		err = kenken.WriteMiniZinc(io.Discard, os.Stdout, p)
+	default:
+		err = fmt.Errorf("unknown output format %q", format)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kenken: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+func writeJSON(p *kenken.Puzzle) int {
+	data, err := json.Marshal(p)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kenken: %v\n", err)
+		return exitFailure
+	}
+	fmt.Println(string(data))
+	return exitOK
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	kenken "github.com/MorganR/KenkenSolver"
+)
+
+const exampleText = `AAB
+CDB
+CDD
+A 1-
+B 3/
+C 5+
+D 6*
+`
+
+func TestRunExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	valid := writeFile(t, dir, "valid.txt", exampleText)
+	keen := writeFile(t, dir, "valid.keen", "2:a_3,a3a2a1\n")
+	json := writeFile(t, dir, "valid.json", `{"size":1,"regions":[{"op":"=","result":1,"cells":[[0,0]]}]}`)
+	unsolveable := writeFile(t, dir, "unsolveable.keen", "2:a_3,a3a2a2\n")
+	ambiguous := writeFile(t, dir, "ambiguous.txt", "AA\nBB\nA 3+\nB 3+\n")
+	invalid := writeFile(t, dir, "invalid.txt", "AB\nBA\nA 3+\nB 3+\n")
+	malformed := writeFile(t, dir, "malformed.txt", "AB\nA\n")
+	model := writeFile(t, dir, "model.txt", "s SATISFIABLE\nv 1 2 0\n")
+	unsat := writeFile(t, dir, "unsat.txt", "s UNSATISFIABLE\n")
+	smtModel := writeFile(t, dir, "model.smt", "sat\n((define-fun c_0_0 () Int 1))\n")
+	tests := []struct {
+		args []string
+		code int
+	}{
+		{[]string{}, exitFailure},
+		{[]string{"unknown"}, exitFailure},
+		{[]string{"solve", valid, keen, json}, exitOK},
+		{[]string{"solve", "-to", "json", valid}, exitOK},
+		{[]string{"solve", "-stats", unsolveable}, exitPuzzle},
+		{[]string{"solve", "-to", "keen", valid}, exitFailure},
+		{[]string{"solve", unsolveable}, exitPuzzle},
+		{[]string{"solve", valid, unsolveable}, exitPuzzle},
+		{[]string{"solve", filepath.Join(dir, "missing.txt")}, exitFailure},
+		{[]string{"validate", valid, unsolveable}, exitOK},
+		{[]string{"validate", invalid}, exitPuzzle},
+		{[]string{"validate", malformed}, exitFailure},
+		{[]string{"validate", "-format", "json", valid}, exitFailure},
+		{[]string{"render", "-to", "keen", valid}, exitOK},
+		{[]string{"render", "-to", "json", keen}, exitOK},
+		{[]string{"render", "-to", "text", json}, exitOK},
+		{[]string{"enter"}, exitFailure},
+		{[]string{"enter", "-size", "64"}, exitFailure},
+		{[]string{"count", valid, json}, exitOK},
+		{[]string{"count", "-limit", "1", keen}, exitOK},
+		{[]string{"count", unsolveable}, exitPuzzle},
+		{[]string{"count", ambiguous}, exitPuzzle},
+		{[]string{"count", "-parallel", valid}, exitOK},
+		{[]string{"count", "-solver", "sat", ambiguous}, exitPuzzle},
+		{[]string{"count", "-solver", "dlx", "-limit", "1", valid}, exitOK},
+		{[]string{"count", "-solver", "missing", valid}, exitFailure},
+		{[]string{"count", "-parallel", "-solver", "dlx", valid}, exitFailure},
+		{[]string{"solve", "-parallel", "-solver", "sat", valid}, exitFailure},
+		{[]string{"solve", "-parallel", "-solver", "parallel", valid}, exitOK},
+		{[]string{"solve", "-parallel", valid, unsolveable}, exitPuzzle},
+		{[]string{"grade", valid, ambiguous}, exitPuzzle},
+		{[]string{"grade", unsolveable}, exitPuzzle},
+		{[]string{"render", "-to", "dimacs", valid}, exitOK},
+		{[]string{"decode", "-model", model, json}, exitOK},
+		{[]string{"decode", "-model", unsat, json}, exitPuzzle},
+		{[]string{"decode", json}, exitFailure},
+		{[]string{"render", "-to", "smt", valid}, exitOK},
+		{[]string{"solve", "-solver", "dlx", valid, keen}, exitOK},
+		{[]string{"solve", "-solver", "sat", "-stats", valid, unsolveable}, exitPuzzle},
+		{[]string{"solve", "-solver", "missing", valid}, exitFailure},
+		{[]string{"render", "-to", "mzn", valid}, exitOK},
+		{[]string{"render", "-to", "dzn", keen}, exitOK},
+		{[]string{"decode", "-from", "smt", "-model", smtModel, json}, exitOK},
+		{[]string{"decode", "-from", "smt", "-model", model, json}, exitPuzzle},
+		{[]string{"decode", "-from", "cnf", "-model", model, json}, exitFailure},
+	}
+	for _, test := range tests {
+		if code, _, _ := capture(t, test.args...); code != test.code {
+			t.Errorf("run(%v) returned %v, expected %v", test.args, code, test.code)
+		}
+	}
+}
+
+func TestRunOutput(t *testing.T) {
+	dir := t.TempDir()
+	valid := writeFile(t, dir, "valid.txt", exampleText)
+	unsolveable := writeFile(t, dir, "unsolveable.keen", "2:a_3,a3a2a2\n")
+	ambiguous := writeFile(t, dir, "ambiguous.txt", "AA\nBB\nA 3+\nB 3+\n")
+	solvedJSON := `{"size":3,"regions":[{"op":"-","result":1,"cells":[[0,2],[1,2]]},` +
+		`{"op":"/","result":3,"cells":[[2,1],[2,2]]},{"op":"+","result":5,"cells":[[0,0],[0,1]]},` +
+		`{"op":"*","result":6,"cells":[[1,0],[2,0],[1,1]]}],"values":[[3,1,2],[2,3,1],[1,2,3]]}` + "\n"
+	tests := []struct {
+		args           []string
+		stdout, stderr string
+	}{
+		{[]string{"solve", "-to", "json", valid}, solvedJSON, ""},
+		{[]string{"solve", "-to", "json", "-solver", "dlx", valid}, solvedJSON, ""},
+		{[]string{"render", "-to", "text", valid}, exampleText, ""},
+		{[]string{"render", "-to", "keen", valid}, "3:a_3a2b_,s1d3a5m6\n", ""},
+		{[]string{"validate", valid}, valid + ": ok\n", ""},
+		{[]string{"count", valid}, valid + ": 1 solution(s)\n", ""},
+		{[]string{"count", "-solver", "sat", "-limit", "2", ambiguous}, ambiguous + ": at least 2 solutions\n", ""},
+		{[]string{"solve", "-parallel", "-solver", "sat", valid}, "", "kenken: -parallel can't be used with -solver sat\n"},
+		{[]string{"grade", ambiguous}, ambiguous + ": Ambiguous (0)\n", ""},
+		{[]string{"solve", unsolveable}, "", "kenken: " + unsolveable + ": Failed solving puzzle after trying 1 paths\n"},
+	}
+	for _, test := range tests {
+		_, stdout, stderr := capture(t, test.args...)
+		if stdout != test.stdout || stderr != test.stderr {
+			t.Errorf("run(%v) wrote:\n%v\n%v\nexpected:\n%v\n%v", test.args, stdout, stderr, test.stdout, test.stderr)
+		}
+	}
+
+	_, stdout, _ := capture(t, "solve", valid)
+	for _, row := range []string{"2┃1│2│3┃", "1┃2│3│1┃", "0┃3│1│2┃"} {
+		if !strings.Contains(stdout, row) {
+			t.Errorf("Solved grid is missing row %q:\n%v", row, stdout)
+		}
+	}
+}
+
+// solveOnly is a Solver that can't count solutions.
+type solveOnly struct{}
+
+func (solveOnly) Solve(ctx context.Context, p *kenken.Puzzle) (kenken.Result, error) {
+	return kenken.Result{Solver: "solve-only"}, nil
+}
+
+var registerSolveOnly sync.Once
+
+func TestRunCountNeedsCounter(t *testing.T) {
+	registerSolveOnly.Do(func() { kenken.RegisterSolver("solve-only", solveOnly{}) })
+	valid := writeFile(t, t.TempDir(), "valid.txt", exampleText)
+	code, stdout, stderr := capture(t, "count", "-solver", "solve-only", valid)
+	if code != exitFailure || stdout != "" || stderr != "kenken: solver \"solve-only\" can't count solutions\n" {
+		t.Errorf("Counting with a solver that can't count returned %v:\n%v\n%v", code, stdout, stderr)
+	}
+	_, _, stderr = capture(t, "count", "-h")
+	if !strings.Contains(stderr, "solve-only") {
+		t.Errorf("Count's help doesn't list the registered solvers:\n%v", stderr)
+	}
+}
+
+// capture runs the command with its standard output and error sent to files,
+// and returns the exit code and what was written.
+func capture(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	stdout, stderr := os.Stdout, os.Stderr
+	defer func() { os.Stdout, os.Stderr = stdout, stderr }()
+	outFile, err := os.Create(filepath.Join(dir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errFile, err := os.Create(filepath.Join(dir, "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout, os.Stderr = outFile, errFile
+	code := run(args)
+	outFile.Close()
+	errFile.Close()
+	out, _ := os.ReadFile(outFile.Name())
+	errOut, _ := os.ReadFile(errFile.Name())
+	return code, string(out), string(errOut)
+}
+
+func writeFile(t *testing.T, dir, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,202 @@
+package kenken
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// cnf is a boolean formula in conjunctive normal form. As in DIMACS,
+// variables are numbered from 1 and a literal is a variable, negated if it
+// must be false.
+type cnf struct {
+	numVars int
+	clauses [][]int
+}
+
+func (f *cnf) add(clause ...int) {
+	f.clauses = append(f.clauses, clause)
+}
+
+// atMostOne adds a clause for every pair of lits forbidding both to be true.
+func (f *cnf) atMostOne(lits []int) {
+	for a := range lits {
+		for b := a + 1; b < len(lits); b++ {
+			f.add(-lits[a], -lits[b])
+		}
+	}
+}
+
+// exactlyOne adds clauses requiring exactly one of lits to be true.
+func (f *cnf) exactlyOne(lits []int) {
+	f.add(append([]int(nil), lits...)...)
+	f.atMostOne(lits)
+}
+
+// cellVar returns the variable that is true when i holds v. Cells are
+// numbered in the order (0,0), (1,0), ..., with one variable per value.
+func (p *Puzzle) cellVar(i Index, v uint8) int {
+	n := int(p.size)
+	return (int(i.Y)*n+int(i.X))*n + int(v)
+}
+
+// cnf encodes the puzzle with one variable per cell and value, and one more
+// for each way of filling a cage. Each cell holds one value, and each value
+// appears once per row and column. A filling forces the values of its cells,
+// and a cell may hold a value only if one of its cage's fillings gives it
+// that value. Values already set, and missing candidates, limit the
+// fillings.
+//
+// Together these make each cage take exactly one filling: two fillings both
+// true would put two values in a cell they differ on, and a cell's value
+// needs one. Listing the support of each cell and value, rather than a
+// clause over all of a cage's fillings, lets a value being ruled out rule
+// out the fillings using it straight away.
+func (p *Puzzle) cnf() *cnf {
+	n := p.size
+	f := &cnf{numVars: int(n) * int(n) * int(n)}
+	lits := make([]int, n)
+	for y := uint8(0); y < n; y++ {
+		for x := uint8(0); x < n; x++ {
+			for v := uint8(1); v <= n; v++ {
+				lits[v-1] = p.cellVar(Index{x, y}, v)
+			}
+			f.exactlyOne(lits)
+		}
+	}
+	for v := uint8(1); v <= n; v++ {
+		for a := uint8(0); a < n; a++ {
+			for b := uint8(0); b < n; b++ {
+				lits[b] = p.cellVar(Index{b, a}, v)
+			}
+			f.exactlyOne(lits)
+			for b := uint8(0); b < n; b++ {
+				lits[b] = p.cellVar(Index{a, b}, v)
+			}
+			f.exactlyOne(lits)
+		}
+	}
+	for ri := range p.regions {
+		// support[k][v] lists the fillings giving the k-th cell v, after the
+		// negated cell variable.
+		var support [][][]int
+		p.regions[ri].forEachAssignment(p.combos(), n, p.allows, func(cells []Index, values []uint8) bool {
+			if support == nil {
+				support = make([][][]int, len(cells))
+				for k, c := range cells {
+					support[k] = make([][]int, n+1)
+					for v := uint8(1); v <= n; v++ {
+						support[k][v] = []int{-p.cellVar(c, v)}
+					}
+				}
+			}
+			f.numVars++
+			for k, c := range cells {
+				f.add(-f.numVars, p.cellVar(c, values[k]))
+				support[k][values[k]] = append(support[k][values[k]], f.numVars)
+			}
+			return false
+		})
+		if support == nil {
+			// No filling fits, so the cage can't be completed.
+			f.add()
+			continue
+		}
+		for k := range support {
+			for v := uint8(1); v <= n; v++ {
+				f.add(support[k][v]...)
+			}
+		}
+	}
+	return f
+}
+
+// WriteDIMACS writes the puzzle as a CNF formula in the DIMACS format used by
+// SAT solvers. Variable (y*n+x)*n+v, for a puzzle of size n, is true when the
+// cell at (x, y) holds v; later variables choose how each cage is filled.
+// ReadDIMACSModel turns a solver's model back into a solution.
+func WriteDIMACS(w io.Writer, p *Puzzle) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	f := p.cnf()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "c kenken puzzle of size %v\n", p.size)
+	fmt.Fprintf(bw, "c variable (y*%v+x)*%v+v is true when cell (x,y) holds v, with y=0 the bottom row\n", p.size, p.size)
+	fmt.Fprintf(bw, "p cnf %v %v\n", f.numVars, len(f.clauses))
+	for _, clause := range f.clauses {
+		for _, lit := range clause {
+			bw.WriteString(strconv.Itoa(lit))
+			bw.WriteByte(' ')
+		}
+		bw.WriteString("0\n")
+	}
+	return bw.Flush()
+}
+
+// ReadDIMACSModel fills in the puzzle from a SAT solver's model of the
+// formula written by WriteDIMACS. It accepts the SAT competition output
+// format, with "s" and "v" lines, as well as the bare "SAT" and values that
+// MiniSat writes. It returns an UnsolveableError if the solver found the
+// formula unsatisfiable, and an error if the model is not a solution.
+func ReadDIMACSModel(r io.Reader, p *Puzzle) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	n := p.size
+	grid := make([][]uint8, n)
+	for y := range grid {
+		grid[y] = make([]uint8, n)
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<24)
+	line, done := 0, false
+	for scanner.Scan() && !done {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "c", "SAT":
+			continue
+		case "s":
+			if len(fields) > 1 && fields[1] == "UNSATISFIABLE" {
+				return UnsolveableError{}
+			}
+			continue
+		case "UNSAT":
+			return UnsolveableError{}
+		case "v":
+			fields = fields[1:]
+		}
+		for _, field := range fields {
+			lit, err := strconv.Atoi(field)
+			if err != nil {
+				return fmt.Errorf("dimacs: line %v: invalid literal %q", line, field)
+			}
+			if lit == 0 {
+				done = true
+				break
+			}
+			if lit < 0 || lit > int(n)*int(n)*int(n) {
+				continue
+			}
+			cell, v := (lit-1)/int(n), uint8((lit-1)%int(n)+1)
+			x, y := cell%int(n), cell/int(n)
+			if grid[y][x] != 0 {
+				return fmt.Errorf("dimacs: model puts both %v and %v at %v", grid[y][x], v, Index{uint8(x), uint8(y)})
+			}
+			grid[y][x] = v
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if err := p.fill(grid); err != nil {
+		return fmt.Errorf("dimacs: %v", err)
+	}
+	return nil
+}
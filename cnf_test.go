@@ -0,0 +1,144 @@
+package kenken
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// modelFor returns the assignment of the formula's variables that matches
+// grid. A cage's filling is chosen if all the cell values it forces hold.
+func modelFor(p *Puzzle, f *cnf, grid [][]uint8) []bool {
+	model := make([]bool, f.numVars+1)
+	for y := range grid {
+		for x, v := range grid[y] {
+			model[p.cellVar(Index{uint8(x), uint8(y)}, v)] = true
+		}
+	}
+	cellVars := int(p.size) * int(p.size) * int(p.size)
+	for v := cellVars + 1; v <= f.numVars; v++ {
+		model[v] = true
+	}
+	for _, clause := range f.clauses {
+		if len(clause) == 2 && -clause[0] > cellVars && !model[clause[1]] {
+			model[-clause[0]] = false
+		}
+	}
+	return model
+}
+
+func unsatisfiedClause(f *cnf, model []bool) []int {
+	for _, clause := range f.clauses {
+		satisfied := false
+		for _, lit := range clause {
+			if (lit > 0 && model[lit]) || (lit < 0 && !model[-lit]) {
+				satisfied = true
+				break
+			}
+		}
+		if !satisfied {
+			return clause
+		}
+	}
+	return nil
+}
+
+func TestCNFSatisfiedBySolution(t *testing.T) {
+	p, _ := examplePuzzleBuilder().Build()
+	f := p.cnf()
+	if clause := unsatisfiedClause(f, modelFor(p, f, exampleSolution())); clause != nil {
+		t.Errorf("The solution does not satisfy clause %v", clause)
+	}
+
+	wrong := exampleSolution()
+	wrong[0], wrong[1] = wrong[1], wrong[0]
+	if unsatisfiedClause(f, modelFor(p, f, wrong)) == nil {
+		t.Errorf("A grid that breaks the cages satisfied every clause")
+	}
+}
+
+func TestWriteDIMACS(t *testing.T) {
+	p, _ := examplePuzzleBuilder().Build()
+	var buf bytes.Buffer
+	if err := WriteDIMACS(&buf, p); err != nil {
+		t.Fatalf("WriteDIMACS failed: %v", err)
+	}
+	f := p.cnf()
+	header := fmt.Sprintf("p cnf %v %v\n", f.numVars, len(f.clauses))
+	if !strings.Contains(buf.String(), header) {
+		t.Errorf("Expected header %q in:\n%v", header, buf.String()[:200])
+	}
+	if lines := strings.Count(buf.String(), " 0\n"); lines != len(f.clauses) {
+		t.Errorf("Wrote %v clauses, expected %v", lines, len(f.clauses))
+	}
+}
+
+func TestReadDIMACSModel(t *testing.T) {
+	p, _ := examplePuzzleBuilder().Build()
+	f := p.cnf()
+	model := modelFor(p, f, exampleSolution())
+	var competition, minisat strings.Builder
+	competition.WriteString("c solved\ns SATISFIABLE\nv")
+	minisat.WriteString("SAT\n")
+	for v := 1; v <= f.numVars; v++ {
+		lit := v
+		if !model[v] {
+			lit = -v
+		}
+		fmt.Fprintf(&competition, " %v", lit)
+		if v%10 == 0 {
+			competition.WriteString("\nv")
+		}
+		fmt.Fprintf(&minisat, "%v ", lit)
+	}
+	competition.WriteString(" 0\n")
+	minisat.WriteString("0\n")
+
+	for _, out := range []string{competition.String(), minisat.String()} {
+		p, _ := examplePuzzleBuilder().Build()
+		if err := ReadDIMACSModel(strings.NewReader(out), p); err != nil {
+			t.Fatalf("ReadDIMACSModel failed: %v", err)
+		}
+		if !sameGrid(p.Grid(), exampleSolution()) {
+			t.Errorf("Read %v, expected %v", p.Grid(), exampleSolution())
+		}
+	}
+}
+
+func TestReadDIMACSModelErrors(t *testing.T) {
+	var unsolveable UnsolveableError
+	for _, out := range []string{"s UNSATISFIABLE\n", "UNSAT\n"} {
+		p, _ := examplePuzzleBuilder().Build()
+		if err := ReadDIMACSModel(strings.NewReader(out), p); !errors.As(err, &unsolveable) {
+			t.Errorf("Expected an UnsolveableError for %q, got: %v", out, err)
+		}
+	}
+
+	wrong := exampleSolution()
+	wrong[0], wrong[1] = wrong[1], wrong[0]
+	var sb strings.Builder
+	sb.WriteString("s SATISFIABLE\nv")
+	for y := range wrong {
+		for x, v := range wrong[y] {
+			fmt.Fprintf(&sb, " %v", (y*5+x)*5+int(v))
+		}
+	}
+	sb.WriteString(" 0\n")
+	bad := []string{
+		sb.String(),
+		"s SATISFIABLE\nv 1 0\n",
+		"s SATISFIABLE\nv 1 2 0\n",
+		"s SATISFIABLE\nv 1 x 0\n",
+	}
+	for _, out := range bad {
+		p, _ := examplePuzzleBuilder().Build()
+		if err := ReadDIMACSModel(strings.NewReader(out), p); err == nil {
+			t.Errorf("Accepted model %q", out)
+		}
+		if p.GetValue(Index{0, 0}) != 0 {
+			t.Errorf("A rejected model changed the puzzle")
+		}
+	}
+}
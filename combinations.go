@@ -0,0 +1,172 @@
+package kenken
+
+import "sync"
+
+// CombinationTable memoizes the multisets of values that satisfy each kind
+// of cage. It's safe for concurrent use, so one table can be shared by many
+// solves, or each solver can keep its own.
+type CombinationTable struct {
+	lock sync.RWMutex
+	maps map[opMapKey]ByteMapList
+}
+
+// defaultCombinations is used by puzzles that haven't been given a table.
+var defaultCombinations = NewCombinationTable()
+
+func NewCombinationTable() *CombinationTable {
+	return &CombinationTable{maps: make(map[opMapKey]ByteMapList)}
+}
+
+// RegionMaps returns the multisets of values that satisfy the region in a
+// puzzle of the given size. The maps must not be modified.
+func (t *CombinationTable) RegionMaps(r *Region, size uint8) ByteMapList {
+	if r.op == Nothing {
+		if r.result > MaxSize {
+			return ByteMapList{}
+		}
+		maps := make(ByteMapList, 1)
+		maps[0] = *NewByteMap()
+		maps[0].Add(byte(r.result))
+		return maps
+	}
+	return t.Maps(r.op, size, uint(r.indices.Len()), r.result)
+}
+
+// Maps returns the multisets of numArgs values from 1 to size that give
+// result under op. For Sub and Div, the largest value less the sum of the
+// others, or divided by their product, gives the result. The maps must not be
+// modified.
+func (t *CombinationTable) Maps(op Operation, size uint8, numArgs uint, result uint) ByteMapList {
+	if size > MaxSize || numArgs == 0 {
+		return nil
+	}
+	// No values multiply to 0, and recursing on the product would never end.
+	if (op == Mul || op == Div) && result == 0 {
+		return nil
+	}
+	key := opMapKey{op, size, numArgs, result}
+	t.lock.RLock()
+	maps, present := t.maps[key]
+	t.lock.RUnlock()
+	if present {
+		return maps
+	}
+	switch op {
+	case Sum:
+		maps = t.sumMaps(size, numArgs, result)
+	case Sub:
+		maps = t.subMaps(size, numArgs, result)
+	case Mul:
+		maps = t.mulMaps(size, numArgs, result)
+	case Div:
+		maps = t.divMaps(size, numArgs, result)
+	default:
+		return nil
+	}
+	t.lock.Lock()
+	t.maps[key] = maps
+	t.lock.Unlock()
+	return maps
+}
+
+// Len returns the number of combinations stored.
+func (t *CombinationTable) Len() int {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return len(t.maps)
+}
+
+// Precompute fills the table for every cage of up to maxCells cells, in
+// puzzles of up to maxSize, with a result that has at least one combination.
+func (t *CombinationTable) Precompute(maxSize uint8, maxCells uint) {
+	for size := uint8(1); size <= maxSize && size != 0; size++ {
+		products := map[uint]bool{1: true}
+		for n := uint(1); n <= maxCells; n++ {
+			for result := n; result <= n*uint(size); result++ {
+				t.Maps(Sum, size, n, result)
+			}
+			next := make(map[uint]bool)
+			for p := range products {
+				for v := uint(1); v <= uint(size); v++ {
+					next[p*v] = true
+				}
+			}
+			products = next
+			for p := range products {
+				t.Maps(Mul, size, n, p)
+			}
+			if n < 2 {
+				continue
+			}
+			for result := uint(1); result <= uint(size); result++ {
+				t.Maps(Sub, size, n, result)
+				t.Maps(Div, size, n, result)
+			}
+		}
+	}
+}
+
+func (t *CombinationTable) sumMaps(size uint8, numArgs uint, result uint) ByteMapList {
+	maps := make(ByteMapList, 0)
+	for i := uint8(1); i <= size && uint(i) <= result; i++ {
+		if numArgs == 1 {
+			if result != uint(i) {
+				continue
+			}
+			m := *NewByteMap()
+			m.Add(i)
+			maps = append(maps, m)
+		} else {
+			if result <= uint(i) {
+				break
+			}
+			innerMaps := t.Maps(Sum, size, numArgs-1, result-uint(i))
+			maps.appendValueAndAdd(&innerMaps, i)
+		}
+	}
+	return maps
+}
+
+func (t *CombinationTable) subMaps(size uint8, numArgs uint, result uint) ByteMapList {
+	maps := make(ByteMapList, 0)
+	for i := uint(result + 1); i <= uint(size); i++ {
+		innerMaps := t.Maps(Sum, size, numArgs-1, i-result)
+		maps.appendValueAndAdd(&innerMaps, uint8(i))
+	}
+	return maps
+}
+
+func (t *CombinationTable) mulMaps(size uint8, numArgs uint, result uint) ByteMapList {
+	maps := make(ByteMapList, 0)
+	if numArgs == 1 {
+		if result <= uint(size) && result <= MaxSize {
+			m := *NewByteMap()
+			m.Add(byte(result))
+			maps = append(maps, m)
+		}
+	} else {
+		for i := uint8(1); i <= size; i++ {
+			if result%uint(i) != 0 {
+				continue
+			}
+			innerMaps := t.Maps(Mul, size, numArgs-1, result/uint(i))
+			maps.appendValueAndAdd(&innerMaps, i)
+		}
+	}
+	return maps
+}
+
+func (t *CombinationTable) divMaps(size uint8, numArgs uint, result uint) ByteMapList {
+	maps := make(ByteMapList, 0)
+	if result == 0 {
+		return maps
+	}
+	for i := uint(1); i <= uint(size); i++ {
+		if i%result != 0 {
+			continue
+		}
+		innerMaps := t.Maps(Mul, size, numArgs-1, i/result)
+		maps.appendValueAndAdd(&innerMaps, uint8(i))
+	}
+	return maps
+}
@@ -0,0 +1,83 @@
+package kenken
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestCombinationTableMemoizes(t *testing.T) {
+	table := NewCombinationTable()
+	first := table.Maps(Sum, 6, 3, 10)
+	n := table.Len()
+	second := table.Maps(Sum, 6, 3, 10)
+	if len(first) == 0 || &first[0] != &second[0] {
+		t.Errorf("Expected the same maps to be returned")
+	}
+	if table.Len() != n {
+		t.Errorf("Table grew from %v to %v on a repeated lookup", n, table.Len())
+	}
+}
+
+func TestCombinationTablePrecompute(t *testing.T) {
+	table := NewCombinationTable()
+	table.Precompute(9, 4)
+	n := table.Len()
+	fresh := NewCombinationTable()
+	keys := []opMapKey{{Sum, 9, 4, 30}, {Mul, 9, 3, 72}, {Sub, 9, 2, 8}, {Div, 9, 2, 3}, {Mul, 7, 4, 2 * 3 * 5 * 7}}
+	for _, k := range keys {
+		got := table.Maps(k.op, k.size, k.numArgs, k.result)
+		exp := fresh.Maps(k.op, k.size, k.numArgs, k.result)
+		compareByteMapLists(t, &got, &exp)
+	}
+	if table.Len() != n {
+		t.Errorf("Precomputed table was missing %v combinations", table.Len()-n)
+	}
+}
+
+func TestCombinationTableNoArgsOrZeroProduct(t *testing.T) {
+	table := NewCombinationTable()
+	for _, op := range []Operation{Sum, Sub, Mul, Div} {
+		if maps := table.Maps(op, 6, 0, 6); len(maps) != 0 {
+			t.Errorf("Expected no maps of no values for %v, got %v", op, maps)
+		}
+	}
+	for _, op := range []Operation{Mul, Div} {
+		if maps := table.Maps(op, 6, 3, 0); len(maps) != 0 {
+			t.Errorf("Expected no maps giving 0 for %v, got %v", op, maps)
+		}
+	}
+}
+
+func TestCombinationTableConcurrent(t *testing.T) {
+	table := NewCombinationTable()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for result := uint(1); result <= 30; result++ {
+				table.Maps(Sum, 9, 4, result)
+				table.Maps(Mul, 9, 3, result)
+			}
+		}()
+	}
+	wg.Wait()
+	exp := NewCombinationTable().Maps(Sum, 9, 4, 20)
+	got := table.Maps(Sum, 9, 4, 20)
+	compareByteMapLists(t, &got, &exp)
+}
+
+func TestPuzzleCombinationTable(t *testing.T) {
+	p, _ := examplePuzzleBuilder().Build()
+	table := NewCombinationTable()
+	p.SetCombinationTable(table)
+	if err := p.Solve(); err != nil {
+		t.Fatalf("Failed to solve puzzle: %v", err)
+	}
+	if table.Len() == 0 {
+		t.Errorf("Puzzle didn't use its own combination table")
+	}
+	if p.Clone().combos() != table {
+		t.Errorf("Clone didn't share the combination table")
+	}
+}
@@ -0,0 +1,652 @@
+package kenken
+
+import (
+	"context"
+	"math"
+	"slices"
+	"time"
+)
+
+// dlx is a sparse 0/1 matrix for Knuth's Algorithm C, exact covering with
+// colors, using dancing links. Node 0 is the root, then come the column
+// headers, and the rest are the 1s of the matrix. Primary columns must be
+// covered exactly once. Secondary columns may be left uncovered, and rows
+// that give one a color may share it with other rows giving the same color.
+type dlx struct {
+	left, right, up, down []int
+	// col is the header of each node's column, and row is the row it's in.
+	col, row []int
+	// color is the color a node gives its secondary column, or 0 for
+	// primary columns. Once a row is chosen, the other nodes in the column
+	// with its color are marked -1 while it stays chosen.
+	color []int
+	// size is the number of 1s left in each column, by header node.
+	size []int
+	// Rows numbered below split are of one kind, and the rest of another.
+	// count holds the number of 1s left of each color in each secondary
+	// column, from each kind of row, so prune can tell when a color is only
+	// given by one kind.
+	split, primary, colors int
+	count                  []int
+	// settled marks the primary columns that have been covered, and the
+	// secondary columns given a color by a chosen row.
+	settled []bool
+	// removed holds a node of each row prune has taken out, in order.
+	removed []int
+	// houses lists the cells of each row and column of the puzzle, numbered
+	// y*size+x like the primary columns their rows cover, and cellNodes holds
+	// the node in that column of the row giving each cell each value, by
+	// cell*colors+value-1, or -1 if there's none.
+	houses    [][]int
+	cellNodes []int
+	// houseRegions lists the primary columns of the regions crossing each
+	// house, and houseSums holds what each region row adds up to in it.
+	// sumCount holds the number of rows left of each region giving each
+	// total to each house it crosses, at the indices rowSums lists for each
+	// region row.
+	houseRegions [][]int
+	houseSums    [][]uint16
+	sumCount     []int
+	rowSums      [][]int
+	// changed marks the houses whose rows have changed since prune last
+	// checked them, and stale the secondary columns that have run out of a
+	// color from one kind of row. cleared holds the marks prune has cleared,
+	// with the columns negated, so restore can put them back.
+	changed []bool
+	stale   []bool
+	cleared []int
+	// picked holds the node of the row chosen for each covered column.
+	picked []int
+	// nodes counts the search nodes visited.
+	nodes uint
+	// ctx, if set, stops the search once it's done, with its error in err.
+	ctx context.Context
+	err error
+}
+
+// newDLX makes a matrix with the given numbers of primary and secondary
+// columns, numbered from 0 with the primary columns first, and with colors
+// numbered from 1 to colors.
+func newDLX(primary, secondary, colors int) *dlx {
+	m := &dlx{
+		split:   math.MaxInt,
+		primary: primary,
+		colors:  colors,
+		count:   make([]int, 2*secondary*(colors+1)),
+		settled: make([]bool, primary+secondary+1),
+		stale:   make([]bool, primary+secondary+1),
+		picked:  make([]int, primary+1),
+	}
+	for i := 0; i <= primary+secondary; i++ {
+		m.left = append(m.left, i-1)
+		m.right = append(m.right, i+1)
+		m.up = append(m.up, i)
+		m.down = append(m.down, i)
+		m.col = append(m.col, i)
+		m.row = append(m.row, -1)
+		m.color = append(m.color, 0)
+		m.size = append(m.size, 0)
+	}
+	m.left[0] = primary
+	m.right[primary] = 0
+	// Only the primary columns are listed from the root, to be covered.
+	for i := primary + 1; i <= primary+secondary; i++ {
+		m.left[i], m.right[i] = i, i
+		m.stale[i] = true
+	}
+	return m
+}
+
+// addRow adds row r with 1s in the given columns, numbered from 0, giving
+// each the color in colors, which must be 0 for primary columns.
+func (m *dlx) addRow(r int, cols, colors []int) {
+	first := -1
+	for k, c := range cols {
+		h := c + 1
+		n := len(m.col)
+		m.col = append(m.col, h)
+		m.row = append(m.row, r)
+		m.color = append(m.color, colors[k])
+		m.up = append(m.up, m.up[h])
+		m.down = append(m.down, h)
+		m.down[m.up[h]] = n
+		m.up[h] = n
+		m.size[h]++
+		m.counted(n, 1)
+		if first < 0 {
+			first = n
+			m.left = append(m.left, n)
+			m.right = append(m.right, n)
+		} else {
+			m.left = append(m.left, m.left[first])
+			m.right = append(m.right, first)
+			m.right[m.left[first]] = n
+			m.left[first] = n
+		}
+	}
+}
+
+// countOf returns the index in count of node i's color and kind of row.
+func (m *dlx) countOf(i int) int {
+	k := ((m.col[i]-m.primary-1)*(m.colors+1) + m.color[i]) * 2
+	if m.row[i] >= m.split {
+		k++
+	}
+	return k
+}
+
+// counted adds d to the count of node i's color, if it gives one, or to the
+// counts of its row's totals, if it's in a region's column, and marks what
+// prune needs to check again.
+func (m *dlx) counted(i, d int) {
+	switch r, h := m.row[i], m.col[i]; {
+	case m.color[i] > 0:
+		k := m.countOf(i)
+		m.count[k] += d
+		if m.count[k] == 0 {
+			m.stale[h] = true
+		}
+	case m.color[i] < 0 || m.changed == nil:
+	case r < m.split:
+		for _, k := range m.rowSums[r] {
+			m.sumCount[k] += d
+			m.changed[m.houseOf(k)] = true
+		}
+	case h <= m.colors*m.colors:
+		// The cells' columns come first, numbered y*size+x.
+		c := h - 1
+		m.changed[2*(c/m.colors)] = true
+		m.changed[2*(c%m.colors)+1] = true
+	}
+}
+
+// hide takes the rest of the row holding node i out of their columns.
+func (m *dlx) hide(i int) {
+	for j := m.right[i]; j != i; j = m.right[j] {
+		if m.color[j] >= 0 {
+			m.down[m.up[j]] = m.down[j]
+			m.up[m.down[j]] = m.up[j]
+			m.size[m.col[j]]--
+			m.counted(j, -1)
+		}
+	}
+}
+
+func (m *dlx) unhide(i int) {
+	for j := m.left[i]; j != i; j = m.left[j] {
+		if m.color[j] >= 0 {
+			m.counted(j, 1)
+			m.size[m.col[j]]++
+			m.down[m.up[j]] = j
+			m.up[m.down[j]] = j
+		}
+	}
+}
+
+func (m *dlx) cover(h int) {
+	m.settled[h] = true
+	for i := m.down[h]; i != h; i = m.down[i] {
+		m.hide(i)
+	}
+	m.right[m.left[h]] = m.right[h]
+	m.left[m.right[h]] = m.left[h]
+}
+
+func (m *dlx) uncover(h int) {
+	m.settled[h] = false
+	m.right[m.left[h]] = h
+	m.left[m.right[h]] = h
+	for i := m.up[h]; i != h; i = m.up[i] {
+		m.unhide(i)
+	}
+}
+
+// purify hides the rows giving the secondary column of node i a color other
+// than its own, and marks the rest.
+func (m *dlx) purify(i int) {
+	c, h := m.color[i], m.col[i]
+	m.settled[h] = true
+	for j := m.down[h]; j != h; j = m.down[j] {
+		if m.color[j] != c {
+			m.hide(j)
+		} else if j != i {
+			m.color[j] = -1
+		}
+	}
+}
+
+func (m *dlx) unpurify(i int) {
+	c, h := m.color[i], m.col[i]
+	m.settled[h] = false
+	for j := m.up[h]; j != h; j = m.up[j] {
+		if m.color[j] < 0 {
+			m.color[j] = c
+		} else if j != i {
+			m.unhide(j)
+		}
+	}
+}
+
+// commit covers the column of node i, in a row being chosen, or purifies it
+// if it's secondary.
+func (m *dlx) commit(i int) {
+	if m.color[i] == 0 {
+		m.cover(m.col[i])
+	} else if m.color[i] > 0 {
+		m.purify(i)
+	}
+}
+
+func (m *dlx) uncommit(i int) {
+	if m.color[i] == 0 {
+		m.uncover(m.col[i])
+	} else if m.color[i] > 0 {
+		m.unpurify(i)
+	}
+}
+
+// remove takes the row holding node i out of the matrix.
+func (m *dlx) remove(i int) {
+	m.hide(i)
+	m.down[m.up[i]] = m.down[i]
+	m.up[m.down[i]] = m.up[i]
+	m.size[m.col[i]]--
+	m.counted(i, -1)
+	m.removed = append(m.removed, i)
+}
+
+// restore puts back the rows removed since removed had length mark, and the
+// marks cleared since cleared had length cleared, as they may need checking
+// again once the search moves on.
+func (m *dlx) restore(removed, cleared int) {
+	for n := len(m.removed) - 1; n >= removed; n-- {
+		i := m.removed[n]
+		m.counted(i, 1)
+		m.size[m.col[i]]++
+		m.down[m.up[i]] = i
+		m.up[m.down[i]] = i
+		m.unhide(i)
+	}
+	m.removed = m.removed[:removed]
+	for _, k := range m.cleared[cleared:] {
+		if k < 0 {
+			m.stale[-k] = true
+		} else {
+			m.changed[k] = true
+		}
+	}
+	m.cleared = m.cleared[:cleared]
+}
+
+// prune removes rows that can't be part of an exact cover, until there are
+// none left to remove. Cell rows must agree with the filling chosen for the
+// cell's region, so a row giving a secondary column a color that no row of
+// the other kind still gives it is removed. And the cells of a house must take
+// different values adding up to its total, so rows outside every way of doing
+// that are removed, as propagateHouse and propagateHouseSum do for Backtrack.
+// Returns false if a house can't be completed.
+func (m *dlx) prune() bool {
+	for changed := true; changed; {
+		changed = false
+		for k, house := range m.houses {
+			if !m.changed[k] {
+				continue
+			}
+			m.changed[k] = false
+			m.cleared = append(m.cleared, k)
+			ok, removed := m.pruneHouse(house)
+			if ok {
+				var more bool
+				ok, more = m.pruneHouseSum(k)
+				removed = removed || more
+			}
+			if !ok {
+				return false
+			}
+			changed = changed || removed
+		}
+		for h := m.primary + 1; h < len(m.settled); h++ {
+			if !m.stale[h] {
+				continue
+			}
+			m.stale[h] = false
+			m.cleared = append(m.cleared, -h)
+			if m.settled[h] {
+				continue
+			}
+			for c := 1; c <= m.colors; c++ {
+				k := ((h-m.primary-1)*(m.colors+1) + c) * 2
+				if (m.count[k] == 0) == (m.count[k+1] == 0) {
+					continue
+				}
+				for i := m.down[h]; i != h; i = m.down[i] {
+					if m.color[i] == c {
+						m.remove(i)
+					}
+				}
+				changed = true
+			}
+		}
+	}
+	return true
+}
+
+// pruneHouseSum removes the region rows whose cells in house k add up to a
+// total the other regions crossing it can't make up to the house's total, as
+// propagateHouseSum does for Backtrack. Returns false if the regions can't
+// make it up at all, and whether any rows were removed.
+func (m *dlx) pruneHouseSum(k int) (bool, bool) {
+	total := m.colors * (m.colors + 1) / 2
+	sums := m.houseSums[k]
+	totals := make([]sumSet, len(m.houseRegions[k]))
+	for j, c := range m.houseRegions[k] {
+		h := c + 1
+		totals[j] = newSumSet(total)
+		if m.settled[h] {
+			totals[j].add(int(sums[m.row[m.picked[h]]]))
+			continue
+		}
+		counts := m.sumCount[m.sumIndex(k, j, 0):m.sumIndex(k, j+1, 0)]
+		for s, n := range counts {
+			if n > 0 {
+				totals[j].add(s)
+			}
+		}
+	}
+	allowed, ok := completeSums(totals, total)
+	if !ok {
+		return false, false
+	}
+	removed := false
+	for j, c := range m.houseRegions[k] {
+		h := c + 1
+		if allowed[j] == nil || m.settled[h] {
+			continue
+		}
+		for i := m.down[h]; i != h; i = m.down[i] {
+			if !allowed[j].has(int(sums[m.row[i]])) {
+				m.remove(i)
+				removed = true
+			}
+		}
+	}
+	return true, removed
+}
+
+// houseOf returns the house of index k in sumCount.
+func (m *dlx) houseOf(k int) int {
+	return k / m.sumIndex(1, 0, 0)
+}
+
+// sumIndex returns the index in sumCount of the number of rows of the jth
+// region crossing house k giving it total s.
+func (m *dlx) sumIndex(k, j, s int) int {
+	total := m.colors * (m.colors + 1) / 2
+	return (k*m.colors+j)*(total+1) + s
+}
+
+// pruneHouse removes the cell rows of the house that can't be part of giving
+// its unsettled cells different values. Returns false if there's no way to,
+// and whether any rows were removed.
+func (m *dlx) pruneHouse(house []int) (bool, bool) {
+	var cells [MaxSize]int
+	var domains, narrowed [MaxSize]PossibleSet
+	k := 0
+	for _, c := range house {
+		// A row still in an unsettled column is linked into it.
+		if m.settled[c+1] {
+			continue
+		}
+		for v := 1; v <= m.colors; v++ {
+			if i := m.cellNodes[c*m.colors+v-1]; i >= 0 && m.down[m.up[i]] == i {
+				domains[k].Add(uint8(v))
+			}
+		}
+		cells[k] = c
+		k++
+	}
+	narrowed = domains
+	if !allDifferent(narrowed[:k]) {
+		return false, false
+	}
+	removed := false
+	for j, c := range cells[:k] {
+		for v := range domains[j].Difference(narrowed[j]).All() {
+			m.remove(m.cellNodes[c*m.colors+int(v)-1])
+			removed = true
+		}
+	}
+	return true, removed
+}
+
+// search calls visit with the rows of each exact cover, always branching on
+// the primary column with the fewest 1s. Returns true if visit stopped the
+// search by returning true, and the number of dead ends found along the way.
+func (m *dlx) search(chosen []int, visit func(rows []int) bool) (bool, uint) {
+	m.nodes++
+	// Check on the first node, so a search that's already stopped does
+	// nothing, and then every 1024.
+	if m.ctx != nil && m.nodes%1024 == 1 {
+		if m.err = m.ctx.Err(); m.err != nil {
+			return true, 0
+		}
+	}
+	if m.right[0] == 0 {
+		return visit(chosen), 0
+	}
+	defer m.restore(len(m.removed), len(m.cleared))
+	if !m.prune() {
+		return false, 1
+	}
+	best := m.right[0]
+	for h := m.right[best]; h != 0 && m.size[best] > 1; h = m.right[h] {
+		if m.size[h] < m.size[best] {
+			best = h
+		}
+	}
+	if m.size[best] == 0 {
+		return false, 1
+	}
+	deadEnds := uint(0)
+	m.cover(best)
+	for i := m.down[best]; i != best; i = m.down[i] {
+		m.picked[best] = i
+		if r := m.row[i]; r < m.split {
+			// Settling a region changes its totals without unlinking it.
+			for _, k := range m.rowSums[r] {
+				m.changed[m.houseOf(k)] = true
+			}
+		}
+		for j := m.right[i]; j != i; j = m.right[j] {
+			m.commit(j)
+		}
+		stopped, d := m.search(append(chosen, m.row[i]), visit)
+		deadEnds += d
+		for j := m.left[i]; j != i; j = m.left[j] {
+			m.uncommit(j)
+		}
+		if stopped {
+			m.uncover(best)
+			return true, deadEnds
+		}
+	}
+	m.uncover(best)
+	return false, deadEnds
+}
+
+// placement is one way of filling a region, or a single cell, as a row of
+// the exact cover matrix.
+type placement struct {
+	cells  []Index
+	values []uint8
+}
+
+// exactCover encodes the puzzle as an exact cover problem with colors. A row
+// for each candidate of each cell covers the cell and the value in its row
+// and column. A row for each way of filling a region covers the region. Both
+// color each cell they place a value in with that value, so the fillings
+// chosen must agree with the cells' values. Only rows that agree with the
+// puzzle's values and candidates are included.
+//
+// Branching on the cells and row and column values, as well as the regions,
+// lets the search narrow a large region's fillings one cell at a time.
+func (p *Puzzle) exactCover() (*dlx, []placement) {
+	n := int(p.size)
+	// The cells' columns come first, for prune.
+	cellCol := 0
+	rowCol := cellCol + n*n
+	colCol := rowCol + n*n
+	regionCol := colCol + n*n
+	colorCol := regionCol + len(p.regions)
+	m := newDLX(colorCol, n*n, n)
+
+	placements := make([]placement, 0)
+	var regionCols []int
+	for ri := range p.regions {
+		p.regions[ri].forEachAssignment(p.combos(), p.size, p.allows, func(cells []Index, values []uint8) bool {
+			pl := placement{append([]Index(nil), cells...), append([]uint8(nil), values...)}
+			cols, colors := []int{regionCol + ri}, []int{0}
+			for k, c := range cells {
+				cols = append(cols, colorCol+int(c.Y)*n+int(c.X))
+				colors = append(colors, int(values[k]))
+			}
+			m.addRow(len(placements), cols, colors)
+			placements = append(placements, pl)
+			regionCols = append(regionCols, regionCol+ri)
+			return false
+		})
+	}
+	m.split = len(placements)
+	m.addHouses(placements, regionCols)
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			c := Index{uint8(x), uint8(y)}
+			for v := 1; v <= n; v++ {
+				if !p.allows(c, uint8(v)) {
+					continue
+				}
+				cols := []int{cellCol + y*n + x, rowCol + y*n + v - 1, colCol + x*n + v - 1, colorCol + y*n + x}
+				m.cellNodes[(y*n+x)*n+v-1] = len(m.col)
+				m.addRow(len(placements), cols, []int{0, 0, 0, v})
+				placements = append(placements, placement{[]Index{c}, []uint8{uint8(v)}})
+			}
+		}
+	}
+	return m, placements
+}
+
+// addHouses sets up the rows and columns of the puzzle for prune, given the
+// placements of the region rows and the primary column of each one's region.
+func (m *dlx) addHouses(placements []placement, regionCols []int) {
+	n := m.colors
+	m.cellNodes = make([]int, n*n*n)
+	for i := range m.cellNodes {
+		m.cellNodes[i] = -1
+	}
+	for k := 0; k < n; k++ {
+		var row, col []int
+		for j := 0; j < n; j++ {
+			row = append(row, k*n+j)
+			col = append(col, j*n+k)
+		}
+		m.houses = append(m.houses, row, col)
+	}
+	m.changed = make([]bool, 2*n)
+	for k := range m.changed {
+		m.changed[k] = true
+	}
+	m.houseRegions = make([][]int, 2*n)
+	m.houseSums = make([][]uint16, 2*n)
+	for k := range m.houseSums {
+		m.houseSums[k] = make([]uint16, len(placements))
+	}
+	rowHouses := make([][]int, len(placements))
+	for r, pl := range placements {
+		for j, c := range pl.cells {
+			for _, k := range []int{2 * int(c.Y), 2*int(c.X) + 1} {
+				m.houseSums[k][r] += uint16(pl.values[j])
+				if !slices.Contains(rowHouses[r], k) {
+					rowHouses[r] = append(rowHouses[r], k)
+				}
+				if !slices.Contains(m.houseRegions[k], regionCols[r]) {
+					m.houseRegions[k] = append(m.houseRegions[k], regionCols[r])
+				}
+			}
+		}
+	}
+	// A house crosses at most n regions, and every row starts out counted.
+	m.sumCount = make([]int, m.sumIndex(2*n, 0, 0))
+	m.rowSums = make([][]int, len(placements))
+	for r, houses := range rowHouses {
+		for _, k := range houses {
+			j := slices.Index(m.houseRegions[k], regionCols[r])
+			i := m.sumIndex(k, j, int(m.houseSums[k][r]))
+			m.rowSums[r] = append(m.rowSums[r], i)
+			m.sumCount[i]++
+		}
+	}
+}
+
+// DLX is a Solver using an exact cover solver, as a check on Backtrack. It
+// gives the same solution for puzzles that have only one.
+type DLX struct{}
+
+func (DLX) Solve(ctx context.Context, p *Puzzle) (Result, error) {
+	result := Result{Solver: "dlx"}
+	if err := p.Validate(); err != nil {
+		return result, err
+	}
+	start := time.Now()
+	m, placements := p.exactCover()
+	m.ctx = ctx
+	var solution []int
+	found, deadEnds := m.search(nil, func(rows []int) bool {
+		solution = append([]int(nil), rows...)
+		return true
+	})
+	result.Stats = SolveStats{Nodes: m.nodes, FailedPaths: deadEnds, Duration: time.Since(start)}
+	if m.err != nil {
+		return result, StoppedError{m.err, result.Stats}
+	}
+	if !found {
+		return result, UnsolveableError{deadEnds}
+	}
+	for _, r := range solution {
+		for k, c := range placements[r].cells {
+			if !p.getBox(c).IsValueSet() {
+				p.place(c, placements[r].values[k])
+			}
+		}
+	}
+	return result, nil
+}
+
+// SolveDLX fills in the puzzle's solution using the DLX solver.
+func (p *Puzzle) SolveDLX() error {
+	_, err := DLX{}.Solve(context.Background(), p)
+	return err
+}
+
+func (DLX) Count(ctx context.Context, p *Puzzle, limit int) (int, error) {
+	if err := p.Validate(); err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	m, _ := p.exactCover()
+	m.ctx = ctx
+	count := 0
+	_, deadEnds := m.search(nil, func([]int) bool {
+		count++
+		return limit > 0 && count >= limit
+	})
+	if m.err != nil {
+		stats := SolveStats{Nodes: m.nodes, FailedPaths: deadEnds, Duration: time.Since(start)}
+		return count, StoppedError{m.err, stats}
+	}
+	return count, nil
+}
+
+// CountSolutionsDLX is CountSolutions using the exact cover solver.
+func (p *Puzzle) CountSolutionsDLX(limit int) (int, error) {
+	return DLX{}.Count(context.Background(), p, limit)
+}
@@ -0,0 +1,80 @@
+package kenken
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSolveDLX(t *testing.T) {
+	builders := []*PuzzleBuilder{examplePuzzleBuilder(), examplePuzzle2Builder()}
+	sols := [][][]uint8{exampleSolution(), exampleSolution2()}
+	for i, b := range builders {
+		p, _ := b.Build()
+		if err := p.SolveDLX(); err != nil {
+			t.Fatalf("Failed to solve example %v: %v", i, err)
+		}
+		if !sameGrid(p.Grid(), sols[i]) {
+			t.Errorf("Example %v was solved as %v, expected %v", i, p.Grid(), sols[i])
+		}
+	}
+}
+
+func TestSolveDLXMatchesSolve(t *testing.T) {
+	for size := uint8(3); size <= 7; size++ {
+		for seed := int64(0); seed < 3; seed++ {
+			p, _ := Generate(GeneratorOptions{Size: size, Seed: seed})
+			q := p.Clone()
+			if err := p.Solve(); err != nil {
+				t.Fatalf("Solve failed: %v", err)
+			}
+			if err := q.SolveDLX(); err != nil {
+				t.Fatalf("SolveDLX failed: %v", err)
+			}
+			if !sameGrid(p.Grid(), q.Grid()) {
+				t.Errorf("Solutions differ for size %v seed %v:\n%v\n%v", size, seed, p, q)
+			}
+		}
+	}
+	p, _ := Parse(strings.NewReader(hardText))
+	if err := p.SolveDLX(); err != nil || !p.isSolved() || p.Validate() != nil {
+		t.Errorf("Failed to solve the hard puzzle: %v", err)
+	}
+}
+
+func TestSolveDLXUnsolveable(t *testing.T) {
+	p, _ := Parse(strings.NewReader(backtrackingText))
+	var unsolveable UnsolveableError
+	if err := p.SolveDLX(); err == nil || !errors.As(err, &unsolveable) {
+		t.Errorf("Expected an UnsolveableError, got: %v", err)
+	}
+}
+
+func TestCountSolutionsDLX(t *testing.T) {
+	p, _ := NewPuzzleBuilder(3).
+		AddCage(Sum, 6, Index{0, 0}, Index{1, 0}, Index{2, 0}).
+		AddCage(Sum, 6, Index{0, 1}, Index{1, 1}, Index{2, 1}).
+		AddCage(Sum, 6, Index{0, 2}, Index{1, 2}, Index{2, 2}).
+		Build()
+	expected, _ := p.CountSolutions(0)
+	if count, err := p.CountSolutionsDLX(0); count != expected || err != nil {
+		t.Errorf("Counted %v solutions, expected %v: %v", count, expected, err)
+	}
+	if count, _ := p.CountSolutionsDLX(5); count != 5 {
+		t.Errorf("Counted %v solutions with a limit of 5", count)
+	}
+}
+
+func BenchmarkSolveDLXHard(b *testing.B) {
+	p, _ := Parse(strings.NewReader(hardText))
+	for i := 0; i < b.N; i++ {
+		p.Clone().SolveDLX()
+	}
+}
+
+func BenchmarkSolveDLXLargeCages(b *testing.B) {
+	p, _ := Parse(strings.NewReader(largeCageText))
+	for i := 0; i < b.N; i++ {
+		p.Clone().SolveDLX()
+	}
+}
@@ -0,0 +1,178 @@
+package kenken
+
+// maxFillingValues bounds the size of a fillingTable. A region with more
+// fillings than fit is checked by enumerating its assignments instead.
+const maxFillingValues = 1 << 22
+
+// fillingTable lists every way of filling a region's cells that meets its
+// operation, ignoring the rest of the board. Filling f gives cells[j] the
+// value values[f*len(cells)+j].
+type fillingTable struct {
+	cells  []Index
+	values []uint8
+	// sums holds, for each row and column the region crosses, what each
+	// filling adds up to in it. Rows come first, then columns.
+	sums map[int][]uint16
+}
+
+func (t *fillingTable) len() int {
+	return len(t.values) / len(t.cells)
+}
+
+func (t *fillingTable) filling(f int32) []uint8 {
+	w := len(t.cells)
+	return t.values[int(f)*w : int(f+1)*w]
+}
+
+// fillingTables returns a fillingTable for each region, by position in
+// p.regions, building them the first time. A region with too many fillings
+// has none.
+func (p *Puzzle) fillingTables() []*fillingTable {
+	if p.fillings != nil {
+		return p.fillings
+	}
+	p.fillings = make([]*fillingTable, len(p.regions))
+	for ri := range p.regions {
+		r := &p.regions[ri]
+		t := &fillingTable{}
+		complete := !r.forEachAssignment(p.combos(), p.size, nil, func(cells []Index, values []uint8) bool {
+			t.cells = cells
+			t.values = append(t.values, values...)
+			return len(t.values) > maxFillingValues
+		})
+		if complete && len(t.values) > 0 {
+			t.addSums(p.size)
+			p.fillings[ri] = t
+		}
+	}
+	return p.fillings
+}
+
+func (t *fillingTable) addSums(size uint8) {
+	t.sums = make(map[int][]uint16)
+	for f := range int32(t.len()) {
+		for j, v := range t.filling(f) {
+			for _, h := range []int{int(t.cells[j].Y), int(size) + int(t.cells[j].X)} {
+				if t.sums[h] == nil {
+					t.sums[h] = make([]uint16, t.len())
+				}
+				t.sums[h][f] += uint16(v)
+			}
+		}
+	}
+}
+
+// liveFillings tracks which fillings of each region are still possible
+// during a search. The first num[ri] entries of ids[ri] are live.
+type liveFillings struct {
+	tables []*fillingTable
+	ids    [][]int32
+	num    []int
+	// trail records the number of live fillings a region had before it
+	// changed, and where the change is marked on the propagation trail.
+	trail []liveChange
+}
+
+type liveChange struct {
+	region, num, mark int
+}
+
+func newLiveFillings(tables []*fillingTable) liveFillings {
+	l := liveFillings{
+		tables: tables,
+		ids:    make([][]int32, len(tables)),
+		num:    make([]int, len(tables)),
+	}
+	for ri, t := range tables {
+		if t == nil {
+			continue
+		}
+		l.num[ri] = t.len()
+		l.ids[ri] = make([]int32, l.num[ri])
+		for f := range l.ids[ri] {
+			l.ids[ri][f] = int32(f)
+		}
+	}
+	return l
+}
+
+// of returns the live fillings of the region.
+func (l *liveFillings) of(ri int) []int32 {
+	return l.ids[ri][:l.num[ri]]
+}
+
+// filter rules out the live fillings of the region that keep rejects.
+// Returns how many were live before, and whether any were ruled out.
+func (l *liveFillings) filter(ri int, keep func(f int32) bool) (int, bool) {
+	ids, n := l.ids[ri], l.num[ri]
+	for i := 0; i < n; {
+		if keep(ids[i]) {
+			i++
+			continue
+		}
+		n--
+		ids[i], ids[n] = ids[n], ids[i]
+	}
+	old := l.num[ri]
+	l.num[ri] = n
+	return old, n != old
+}
+
+// filterLive rules out the live fillings of the region that keep rejects,
+// leaving a mark on the propagation trail so undo can bring them back.
+// Returns whether any were ruled out.
+func (p *Puzzle) filterLive(ri int, keep func(f int32) bool) bool {
+	old, changed := p.prop.live.filter(ri, keep)
+	if !changed {
+		return false
+	}
+	// Consecutive changes to a region only need the first recorded.
+	mark, trail := len(p.prop.trail), p.prop.live.trail
+	if n := len(trail); n > 0 && trail[n-1].region == ri && trail[n-1].mark == mark-1 {
+		return true
+	}
+	p.prop.live.trail = append(trail, liveChange{ri, old, mark})
+	p.prop.trail = append(p.prop.trail, Candidate{})
+	return true
+}
+
+// undo brings back the fillings ruled out since the propagation trail had
+// length mark.
+func (l *liveFillings) undo(mark int) {
+	n := len(l.trail)
+	for ; n > 0 && l.trail[n-1].mark >= mark; n-- {
+		c := l.trail[n-1]
+		l.num[c.region] = c.num
+	}
+	l.trail = l.trail[:n]
+}
+
+// liveSupport rules out the fillings of the region that the board no longer
+// allows, and returns the values each of its cells takes in the rest, in the
+// order of its fillingTable.
+func (p *Puzzle) liveSupport(ri int) []PossibleSet {
+	t := p.fillings[ri]
+	allowed := make([]PossibleSet, len(t.cells))
+	for j, c := range t.cells {
+		if box := p.getBox(c); box.IsValueSet() {
+			allowed[j].Add(box.GetValue())
+		} else {
+			allowed[j] = box.Possibles()
+		}
+	}
+	p.filterLive(ri, func(f int32) bool {
+		for j, v := range t.filling(f) {
+			if !allowed[j].Contains(v) {
+				return false
+			}
+		}
+		return true
+	})
+	support := make([]PossibleSet, len(t.cells))
+	for _, f := range p.prop.live.of(ri) {
+		for j, v := range t.filling(f) {
+			support[j].Add(v)
+		}
+	}
+	return support
+}
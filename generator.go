@@ -0,0 +1,402 @@
+package kenken
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+)
+
+// GeneratorOptions controls the puzzles made by Generate.
+type GeneratorOptions struct {
+	// Size is the width of the puzzle.
+	Size uint8
+	// CageSizes gives the relative weight of each cage size, with
+	// CageSizes[i] for cages of i+1 cells. The default favours cages of two
+	// and three cells.
+	CageSizes []int
+	// Ops lists the operations that may be used in cages of more than one
+	// cell. Sub and Div are only used in two cell cages. The default is all
+	// four.
+	Ops []Operation
+	// Seed makes the puzzle reproducible: the same options always give the
+	// same puzzle.
+	Seed int64
+	// MaxAttempts is the number of random grids tried before settling for
+	// a puzzle with more single cell cages than usual. A grid whose puzzle
+	// takes too long to check for a second solution counts as a failed
+	// attempt. Defaults to 10.
+	MaxAttempts int
+}
+
+var defaultCageSizes = []int{1, 5, 3, 1}
+
+type generatedCage struct {
+	cells  []Index
+	op     Operation
+	result uint
+}
+
+type generator struct {
+	opts     GeneratorOptions
+	rng      *rand.Rand
+	solution [][]uint8
+	cages    []generatedCage
+}
+
+// Generate creates a random puzzle with exactly one solution. It fills a
+// random latin square, splits it into cages and gives each cage an operation.
+// While the puzzle is ambiguous, a cell that differs between two solutions is
+// split off into its own cage. A grid whose puzzle takes too long to check is
+// replaced by a new one.
+func Generate(opts GeneratorOptions) (*Puzzle, error) {
+	if opts.Size == 0 {
+		return nil, fmt.Errorf("puzzle size must be at least 1")
+	}
+	if opts.CageSizes == nil {
+		opts.CageSizes = defaultCageSizes
+	}
+	total := 0
+	for _, w := range opts.CageSizes {
+		if w < 0 {
+			return nil, fmt.Errorf("cage size weights must not be negative")
+		}
+		total += w
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("at least one cage size must have a positive weight")
+	}
+	if opts.Ops == nil {
+		opts.Ops = []Operation{Sum, Sub, Mul, Div}
+	}
+	for _, op := range opts.Ops {
+		if op < Sum || op > Div {
+			return nil, fmt.Errorf("cannot generate cages with operation %v", op)
+		}
+	}
+	if opts.MaxAttempts < 1 {
+		opts.MaxAttempts = 10
+	}
+
+	g := &generator{opts: opts, rng: rand.New(rand.NewSource(opts.Seed))}
+	for attempt := 1; ; attempt++ {
+		g.fillSolution()
+		g.partition()
+		maxRepairs := -1
+		if attempt < opts.MaxAttempts {
+			maxRepairs = 2 * int(opts.Size)
+		}
+		p, err := g.makeUnique(maxRepairs)
+		if p != nil || err != nil {
+			return p, err
+		}
+	}
+}
+
+// fillSolution creates a random latin square with the Jacobson-Matthews
+// Markov chain, starting from a cyclic square. The square is kept as an
+// incidence cube with a 1 at (x, y, v) when v is at (x, y). Each move adds
+// and removes 1s around a cell without a 1, and may leave one entry at -1 for
+// the next move to repair; after enough moves the square is close to
+// uniformly random.
+func (g *generator) fillSolution() {
+	n := int(g.opts.Size)
+	cube := make([]int8, n*n*n)
+	at := func(x, y, v int) *int8 { return &cube[(x*n+y)*n+v] }
+	for x := 0; x < n; x++ {
+		for y := 0; y < n; y++ {
+			*at(x, y, (x+y)%n) = 1
+		}
+	}
+	// With fewer than 3 values there may be no cell without a 1 to move.
+	moves := 0
+	if n > 2 {
+		moves = n * n * n
+	}
+	improper, bad := false, [3]int{}
+	for step := 0; step < moves || improper; step++ {
+		var x, y, v int
+		if improper {
+			x, y, v = bad[0], bad[1], bad[2]
+		} else {
+			for {
+				x, y, v = g.rng.Intn(n), g.rng.Intn(n), g.rng.Intn(n)
+				if *at(x, y, v) == 0 {
+					break
+				}
+			}
+		}
+		x1 := g.pickOne(n, func(i int) int8 { return *at(i, y, v) })
+		y1 := g.pickOne(n, func(i int) int8 { return *at(x, i, v) })
+		v1 := g.pickOne(n, func(i int) int8 { return *at(x, y, i) })
+		*at(x, y, v)++
+		*at(x1, y, v)--
+		*at(x, y1, v)--
+		*at(x, y, v1)--
+		*at(x1, y1, v)++
+		*at(x1, y, v1)++
+		*at(x, y1, v1)++
+		*at(x1, y1, v1)--
+		improper, bad = *at(x1, y1, v1) < 0, [3]int{x1, y1, v1}
+	}
+	// Shuffling the symbols too lets the smallest squares, which never move,
+	// vary.
+	symbols := g.rng.Perm(n)
+	g.solution = make([][]uint8, n)
+	for y := range g.solution {
+		g.solution[y] = make([]uint8, n)
+		for x := range g.solution[y] {
+			for v := 0; v < n; v++ {
+				if *at(x, y, v) == 1 {
+					g.solution[y][x] = uint8(symbols[v] + 1)
+				}
+			}
+		}
+	}
+}
+
+// pickOne returns a random i from 0 to n-1 where entry(i) is 1.
+func (g *generator) pickOne(n int, entry func(i int) int8) int {
+	ones := make([]int, 0, 2)
+	for i := 0; i < n; i++ {
+		if entry(i) == 1 {
+			ones = append(ones, i)
+		}
+	}
+	return ones[g.rng.Intn(len(ones))]
+}
+
+// partition splits the grid into contiguous cages, growing each one from a
+// random cell towards a randomly chosen size.
+func (g *generator) partition() {
+	n := int(g.opts.Size)
+	taken := make([][]bool, n)
+	for y := range taken {
+		taken[y] = make([]bool, n)
+	}
+	g.cages = g.cages[:0]
+	for _, i := range g.rng.Perm(n * n) {
+		start := Index{uint8(i % n), uint8(i / n)}
+		if taken[start.Y][start.X] {
+			continue
+		}
+		size := g.pickCageSize()
+		taken[start.Y][start.X] = true
+		cells := []Index{start}
+		for len(cells) < size {
+			frontier := make([]Index, 0)
+			for _, c := range cells {
+				for _, nb := range neighbours(c, g.opts.Size) {
+					if !taken[nb.Y][nb.X] {
+						frontier = append(frontier, nb)
+					}
+				}
+			}
+			if len(frontier) == 0 {
+				break
+			}
+			next := frontier[g.rng.Intn(len(frontier))]
+			taken[next.Y][next.X] = true
+			cells = append(cells, next)
+		}
+		g.addCage(cells)
+	}
+}
+
+func (g *generator) pickCageSize() int {
+	total := 0
+	for _, w := range g.opts.CageSizes {
+		total += w
+	}
+	r := g.rng.Intn(total)
+	for i, w := range g.opts.CageSizes {
+		if r < w {
+			return i + 1
+		}
+		r -= w
+	}
+	return 1
+}
+
+// addCage adds the cells as a cage with a random operation that fits their
+// values. If none of the allowed operations fit, each cell becomes its own
+// cage instead.
+func (g *generator) addCage(cells []Index) {
+	if len(cells) == 1 {
+		g.cages = append(g.cages, generatedCage{cells, Nothing, uint(g.solution[cells[0].Y][cells[0].X])})
+		return
+	}
+	values := make([]uint, len(cells))
+	sum, product, max, min := uint(0), uint(1), uint(0), uint(g.opts.Size)+1
+	for i, c := range cells {
+		values[i] = uint(g.solution[c.Y][c.X])
+		sum += values[i]
+		product *= values[i]
+		if values[i] > max {
+			max = values[i]
+		}
+		if values[i] < min {
+			min = values[i]
+		}
+	}
+	options := make([]generatedCage, 0, len(g.opts.Ops))
+	for _, op := range g.opts.Ops {
+		switch {
+		case op == Sum:
+			options = append(options, generatedCage{cells, Sum, sum})
+		case op == Mul:
+			options = append(options, generatedCage{cells, Mul, product})
+		case op == Sub && len(cells) == 2:
+			options = append(options, generatedCage{cells, Sub, max - min})
+		case op == Div && len(cells) == 2 && max%min == 0:
+			options = append(options, generatedCage{cells, Div, max / min})
+		}
+	}
+	if len(options) == 0 {
+		for _, c := range cells {
+			g.addCage([]Index{c})
+		}
+		return
+	}
+	g.cages = append(g.cages, options[g.rng.Intn(len(options))])
+}
+
+func (g *generator) build() (*Puzzle, error) {
+	b := NewPuzzleBuilder(g.opts.Size)
+	for _, c := range g.cages {
+		b.AddCage(c.op, c.result, c.cells...)
+	}
+	return b.Build()
+}
+
+// uniquenessNodes bounds the search for a second solution made after each
+// repair. Puzzles that take longer to check are too hard to be worth it.
+const uniquenessNodes = 20000
+
+// makeUnique repairs the cages until the puzzle has one solution. It gives up
+// and returns nil after maxRepairs repairs, or if checking for a second
+// solution takes too long, unless maxRepairs is negative. Then a cell of the
+// largest cage is split off instead, to make the puzzle easier to check.
+func (g *generator) makeUnique(maxRepairs int) (*Puzzle, error) {
+	for repairs := 0; maxRepairs < 0 || repairs <= maxRepairs; repairs++ {
+		p, err := g.build()
+		if err != nil {
+			return nil, err
+		}
+		other, ok := g.otherSolution(p)
+		if !ok {
+			if maxRepairs >= 0 {
+				return nil, nil
+			}
+			largest := g.largestCage()
+			g.isolate(largest[g.rng.Intn(len(largest))])
+			continue
+		}
+		if other == nil {
+			return p, nil
+		}
+		differing := make([]Index, 0)
+		for y := range other {
+			for x := range other[y] {
+				if other[y][x] != g.solution[y][x] {
+					differing = append(differing, Index{uint8(x), uint8(y)})
+				}
+			}
+		}
+		g.isolate(differing[g.rng.Intn(len(differing))])
+	}
+	return nil, nil
+}
+
+// otherSolution returns a solution of the puzzle other than g.solution, or
+// nil if there is none. It returns false if the search ran out of nodes
+// first.
+func (g *generator) otherSolution(p *Puzzle) ([][]uint8, bool) {
+	var other [][]uint8
+	c := p.Clone()
+	c.run = &solveRun{ctx: context.Background(), opts: SolveOptions{MaxNodes: uniquenessNodes}}
+	c.search(func() bool {
+		if grid := c.Grid(); !sameGrid(grid, g.solution) {
+			other = grid
+			return true
+		}
+		return false
+	})
+	return other, other != nil || c.run.err == nil
+}
+
+// largestCage returns the cells of the cage with the most.
+func (g *generator) largestCage() []Index {
+	largest := g.cages[0].cells
+	for _, c := range g.cages {
+		if len(c.cells) > len(largest) {
+			largest = c.cells
+		}
+	}
+	return largest
+}
+
+// isolate moves the cell into a cage of its own. The rest of its old cage is
+// split into contiguous pieces, each given a new operation.
+func (g *generator) isolate(cell Index) {
+	for i, c := range g.cages {
+		rest := *NewIndexSet()
+		found := false
+		for _, idx := range c.cells {
+			if idx == cell {
+				found = true
+			} else {
+				rest.Add(idx)
+			}
+		}
+		if !found {
+			continue
+		}
+		g.cages = append(g.cages[:i], g.cages[i+1:]...)
+		g.addCage([]Index{cell})
+		for _, start := range rest.SortedSlice() {
+			if !rest.Contains(start) {
+				continue
+			}
+			piece := []Index{start}
+			rest.Drop(start)
+			for j := 0; j < len(piece); j++ {
+				for _, nb := range neighbours(piece[j], g.opts.Size) {
+					if rest.Contains(nb) {
+						rest.Drop(nb)
+						piece = append(piece, nb)
+					}
+				}
+			}
+			g.addCage(piece)
+		}
+		return
+	}
+}
+
+func neighbours(i Index, size uint8) []Index {
+	n := make([]Index, 0, 4)
+	if i.X > 0 {
+		n = append(n, Index{i.X - 1, i.Y})
+	}
+	if i.X < size-1 {
+		n = append(n, Index{i.X + 1, i.Y})
+	}
+	if i.Y > 0 {
+		n = append(n, Index{i.X, i.Y - 1})
+	}
+	if i.Y < size-1 {
+		n = append(n, Index{i.X, i.Y + 1})
+	}
+	return n
+}
+
+func sameGrid(a, b [][]uint8) bool {
+	for y := range a {
+		for x := range a[y] {
+			if a[y][x] != b[y][x] {
+				return false
+			}
+		}
+	}
+	return true
+}
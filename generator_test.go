@@ -0,0 +1,139 @@
+package kenken
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestGenerateUnique(t *testing.T) {
+	for size := uint8(1); size <= 6; size++ {
+		for seed := int64(0); seed < 3; seed++ {
+			p, err := Generate(GeneratorOptions{Size: size, Seed: seed})
+			if err != nil {
+				t.Fatalf("Generate failed with error: %v", err)
+			}
+			if unique, err := p.IsUnique(); !unique || err != nil {
+				t.Errorf("Generated puzzle of size %v with seed %v was not unique:\n%v", size, seed, p)
+			}
+		}
+	}
+}
+
+func TestGenerateLargeCages(t *testing.T) {
+	opts := GeneratorOptions{Size: 9, Seed: 4, CageSizes: []int{0, 1, 2, 3, 3, 2}}
+	p, err := Generate(opts)
+	if err != nil {
+		t.Fatalf("Generate failed with error: %v", err)
+	}
+	largest := 0
+	for _, r := range p.GetRegions() {
+		largest = max(largest, len(r.GetIndices()))
+	}
+	if largest < 5 {
+		t.Errorf("Expected cages of 5 or 6 cells, the largest had %v", largest)
+	}
+	if unique, err := p.IsUnique(); !unique || err != nil {
+		t.Errorf("Generated puzzle was not unique:\n%v", p)
+	}
+}
+
+func TestGenerateIsReproducible(t *testing.T) {
+	opts := GeneratorOptions{Size: 5, Seed: 42}
+	var first, second bytes.Buffer
+	p, _ := Generate(opts)
+	Write(&first, p)
+	p, _ = Generate(opts)
+	Write(&second, p)
+	if first.String() != second.String() {
+		t.Errorf("The same seed gave different puzzles:\n%v\n%v", first.String(), second.String())
+	}
+	opts.Seed = 43
+	second.Reset()
+	p, _ = Generate(opts)
+	Write(&second, p)
+	if first.String() == second.String() {
+		t.Errorf("Different seeds gave the same puzzle:\n%v", first.String())
+	}
+}
+
+func TestGenerateOptions(t *testing.T) {
+	opts := GeneratorOptions{Size: 5, Seed: 7, CageSizes: []int{0, 0, 1}, Ops: []Operation{Sum}}
+	p, err := Generate(opts)
+	if err != nil {
+		t.Fatalf("Generate failed with error: %v", err)
+	}
+	for _, r := range p.GetRegions() {
+		if r.GetOp() != Sum && r.GetOp() != Nothing {
+			t.Errorf("Generated a region with op %v", r.GetOp())
+		}
+		if len(r.GetIndices()) > 3 {
+			t.Errorf("Generated a region with %v cells", len(r.GetIndices()))
+		}
+	}
+
+	p, err = Generate(GeneratorOptions{Size: 4, Seed: 1, Ops: []Operation{Div}})
+	if err != nil {
+		t.Fatalf("Generate failed with error: %v", err)
+	}
+	for _, r := range p.GetRegions() {
+		if r.GetOp() == Div && len(r.GetIndices()) != 2 {
+			t.Errorf("Generated a Div region with %v cells", len(r.GetIndices()))
+		}
+	}
+}
+
+func TestGenerateRejectsBadOptions(t *testing.T) {
+	bad := []GeneratorOptions{
+		{Size: 0},
+		{Size: 4, CageSizes: []int{0, 0}},
+		{Size: 4, CageSizes: []int{1, -1}},
+		{Size: 4, Ops: []Operation{Nothing}},
+	}
+	for _, opts := range bad {
+		if _, err := Generate(opts); err == nil {
+			t.Errorf("Generate accepted options %+v", opts)
+		}
+	}
+}
+
+func TestFillSolutionIsRandomLatinSquare(t *testing.T) {
+	// A square isotopic to the cyclic one of size 4 has 4 intercalates, 2x2
+	// latin subsquares, while the other kind has 12.
+	kinds := make(map[int]bool)
+	for seed := int64(0); seed < 40; seed++ {
+		g := &generator{opts: GeneratorOptions{Size: 4}, rng: rand.New(rand.NewSource(seed))}
+		g.fillSolution()
+		s := g.solution
+		for y := range s {
+			for x := range s[y] {
+				for k := 0; k < x; k++ {
+					if s[y][k] == s[y][x] {
+						t.Fatalf("Row %v repeats a value: %v", y, s)
+					}
+				}
+				for k := 0; k < y; k++ {
+					if s[k][x] == s[y][x] {
+						t.Fatalf("Column %v repeats a value: %v", x, s)
+					}
+				}
+			}
+		}
+		intercalates := 0
+		for y1 := 0; y1 < 4; y1++ {
+			for y2 := y1 + 1; y2 < 4; y2++ {
+				for x1 := 0; x1 < 4; x1++ {
+					for x2 := x1 + 1; x2 < 4; x2++ {
+						if s[y1][x1] == s[y2][x2] && s[y1][x2] == s[y2][x1] {
+							intercalates++
+						}
+					}
+				}
+			}
+		}
+		kinds[intercalates] = true
+	}
+	if !kinds[4] || !kinds[12] {
+		t.Errorf("Expected squares with 4 and 12 intercalates, found %v", kinds)
+	}
+}
@@ -0,0 +1,126 @@
+package kenken
+
+import "fmt"
+
+// Tier is a named band of difficulty.
+type Tier uint8
+
+const (
+	Unsolvable Tier = 0
+	Easy       Tier = 1
+	Medium     Tier = 2
+	Hard       Tier = 3
+	Expert     Tier = 4
+	Extreme    Tier = 5
+)
+
+func (t Tier) String() string {
+	switch t {
+	case Easy:
+		return "Easy"
+	case Medium:
+		return "Medium"
+	case Hard:
+		return "Hard"
+	case Expert:
+		return "Expert"
+	case Extreme:
+		return "Extreme"
+	default:
+		return "Unsolvable"
+	}
+}
+
+// techniqueWeights is the score added each time a technique is applied.
+var techniqueWeights = map[Technique]uint{
+	NakedSingle:     1,
+	HiddenSingle:    2,
+	CageCombination: 4,
+	NakedPair:       8,
+	HiddenPair:      10,
+	NakedTriple:     14,
+	HiddenTriple:    16,
+	InnieOutie:      20,
+}
+
+const (
+	// guessWeight is added once if deduction stalls and the rest of the
+	// puzzle has to be searched.
+	guessWeight = 100
+	// failedPathWeight is added for each path the search abandons.
+	failedPathWeight = 10
+)
+
+// Difficulty rates how hard a puzzle is for a person to solve.
+type Difficulty struct {
+	// Score grows with the number and difficulty of the steps needed. It only
+	// changes if the techniques or weights change.
+	Score uint
+	Tier  Tier
+	// Techniques counts how many times each technique was applied.
+	Techniques map[Technique]int
+	// Hardest is the hardest technique applied, or 0 if none were.
+	Hardest Technique
+	// Guessed is true if deduction stalled and search had to finish the
+	// puzzle.
+	Guessed bool
+	// FailedPaths is the number of paths the search abandoned after
+	// deduction stalled.
+	FailedPaths uint
+	// Ambiguous is true if the puzzle has more than one solution, so it can't
+	// be solved by deduction and isn't graded.
+	Ambiguous bool
+}
+
+func (d Difficulty) String() string {
+	if d.Ambiguous {
+		return fmt.Sprintf("Ambiguous (%v)", d.Score)
+	}
+	return fmt.Sprintf("%v (%v)", d.Tier, d.Score)
+}
+
+// Grade rates the puzzle by solving a copy of it the way a person would. The
+// tier comes from the hardest technique needed, or Extreme if deduction alone
+// can't solve it. Invalid or unsolvable puzzles are graded Unsolvable, and
+// puzzles with more than one solution are marked Ambiguous and left ungraded.
+func Grade(p *Puzzle) Difficulty {
+	d := Difficulty{Techniques: make(map[Technique]int)}
+	count, err := p.CountSolutions(2)
+	if err != nil || count == 0 {
+		return d
+	}
+	if count > 1 {
+		d.Ambiguous = true
+		return d
+	}
+	w := p.Clone()
+	for _, s := range w.deduce() {
+		d.Techniques[s.Technique]++
+		d.Score += techniqueWeights[s.Technique]
+		if s.Technique > d.Hardest {
+			d.Hardest = s.Technique
+		}
+	}
+	if !w.isSolved() {
+		d.Guessed = true
+		found, failed := w.search(func() bool { return true })
+		if !found {
+			return Difficulty{Techniques: d.Techniques}
+		}
+		d.FailedPaths = failed
+		d.Score += guessWeight + failedPathWeight*failed
+	}
+	switch {
+	case d.Guessed:
+		d.Tier = Extreme
+	case d.Hardest >= InnieOutie:
+		d.Tier = Expert
+	case d.Hardest >= NakedPair:
+		d.Tier = Hard
+	case d.Hardest >= CageCombination:
+		d.Tier = Medium
+	default:
+		d.Tier = Easy
+	}
+	return d
+}
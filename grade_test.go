@@ -0,0 +1,72 @@
+package kenken
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGradeGivens(t *testing.T) {
+	p, _ := NewPuzzleBuilder(2).
+		AddCage(Nothing, 1, Index{0, 0}).
+		AddCage(Nothing, 2, Index{1, 0}).
+		AddCage(Nothing, 2, Index{0, 1}).
+		AddCage(Nothing, 1, Index{1, 1}).
+		Build()
+	d := Grade(p)
+	if d.Tier != Easy || d.Score != 4 || d.Techniques[NakedSingle] != 4 || d.Guessed {
+		t.Errorf("Expected an easy puzzle with four naked singles, got: %+v", d)
+	}
+}
+
+func TestGradeExamples(t *testing.T) {
+	for i, b := range []*PuzzleBuilder{examplePuzzleBuilder(), examplePuzzle2Builder()} {
+		p, _ := b.Build()
+		d := Grade(p)
+		if d.Tier == Unsolvable || d.Tier == Extreme || d.Guessed {
+			t.Errorf("Example %v should be solvable by deduction, got: %+v", i, d)
+		}
+		if d.Score == 0 || d.Hardest == 0 {
+			t.Errorf("Example %v had no score: %+v", i, d)
+		}
+		if again := Grade(p); again.Score != d.Score || again.Tier != d.Tier {
+			t.Errorf("Grading example %v twice gave %v and %v", i, d, again)
+		}
+	}
+}
+
+func TestGradeNeedsGuessing(t *testing.T) {
+	p, _ := Parse(strings.NewReader(hardText))
+	d := Grade(p)
+	if d.Tier != Extreme || !d.Guessed || d.Score < guessWeight {
+		t.Errorf("Expected an extreme puzzle, got: %+v", d)
+	}
+}
+
+func TestGradeAmbiguous(t *testing.T) {
+	p, _ := NewPuzzleBuilder(3).
+		AddCage(Sum, 6, Index{0, 0}, Index{1, 0}, Index{2, 0}).
+		AddCage(Sum, 6, Index{0, 1}, Index{1, 1}, Index{2, 1}).
+		AddCage(Sum, 6, Index{0, 2}, Index{1, 2}, Index{2, 2}).
+		Build()
+	if d := Grade(p); !d.Ambiguous || d.Tier != Unsolvable || d.Score != 0 || d.Guessed {
+		t.Errorf("Expected an ambiguous puzzle, got: %+v", d)
+	}
+	if p.GetValue(Index{0, 0}) != 0 {
+		t.Errorf("Grade modified the puzzle")
+	}
+}
+
+func TestGradeUnsolvable(t *testing.T) {
+	p, _ := NewPuzzleBuilder(2).
+		AddCage(Nothing, 1, Index{0, 0}).
+		AddCage(Nothing, 1, Index{1, 0}).
+		AddCage(Sum, 3, Index{0, 1}, Index{1, 1}).
+		Build()
+	if d := Grade(p); d.Tier != Unsolvable {
+		t.Errorf("Expected an unsolvable puzzle, got: %+v", d)
+	}
+	p = NewPuzzle(2)
+	if d := Grade(p); d.Tier != Unsolvable {
+		t.Errorf("Expected an invalid puzzle to be unsolvable, got: %+v", d)
+	}
+}
@@ -2,6 +2,7 @@ package kenken
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -68,3 +69,19 @@ func (is IndexSet) String() string {
 	sb.WriteString("]")
 	return sb.String()
 }
+
+// SortedSlice returns the indices ordered by row and then by column, so that
+// callers needing a stable order don't depend on map iteration.
+func (is IndexSet) SortedSlice() []Index {
+	s := is.Slice()
+	sort.Slice(s, func(i, j int) bool { return s[i].Less(s[j]) })
+	return s
+}
+
+// Less orders indices by row and then by column.
+func (i Index) Less(o Index) bool {
+	if i.Y != o.Y {
+		return i.Y < o.Y
+	}
+	return i.X < o.X
+}
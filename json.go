@@ -0,0 +1,238 @@
+package kenken
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+)
+
+// Puzzles are encoded as:
+//
+//	{
+//	  "size": 3,
+//	  "regions": [{"op": "-", "result": 1, "cells": [[0, 2], [1, 2]]}, ...],
+//	  "values": [[3, 1, 2], [2, 3, 1], [1, 2, 3]],
+//	  "candidates": [[[], [1, 3], [2]], ...]
+//	}
+//
+// Cells are [x, y] pairs, and values and candidates are indexed [y][x] with
+// y = 0 as the bottom row, the same as the puzzle itself. A value of 0 means
+// unsolved. Cells are sorted by row and then column, and candidates in
+// increasing order, so the same puzzle always gives the same output.
+
+// JSONOptions selects the optional parts of a puzzle's JSON encoding.
+type JSONOptions struct {
+	// Values includes the grid of values found so far.
+	Values bool
+	// Candidates includes the values still possible for each unsolved box.
+	Candidates bool
+}
+
+type puzzleJSON struct {
+	Size       uint8      `json:"size"`
+	Regions    []Region   `json:"regions"`
+	Values     [][]uint   `json:"values,omitempty"`
+	Candidates [][][]uint `json:"candidates,omitempty"`
+}
+
+type regionJSON struct {
+	Op     Operation `json:"op"`
+	Result uint      `json:"result"`
+	Cells  []Index   `json:"cells"`
+}
+
+func (o Operation) MarshalJSON() ([]byte, error) {
+	if _, ok := OperationFromSymbol(o.Symbol()); !ok {
+		return nil, fmt.Errorf("cannot encode unknown operation %d", uint8(o))
+	}
+	return json.Marshal(o.Symbol())
+}
+
+func (o *Operation) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	op, ok := OperationFromSymbol(s)
+	if !ok {
+		return fmt.Errorf("unknown operation %q", s)
+	}
+	*o = op
+	return nil
+}
+
+func (i Index) MarshalJSON() ([]byte, error) {
+	return json.Marshal([2]uint8{i.X, i.Y})
+}
+
+func (i *Index) UnmarshalJSON(data []byte) error {
+	var xy []uint8
+	if err := json.Unmarshal(data, &xy); err != nil {
+		return err
+	}
+	if len(xy) != 2 {
+		return fmt.Errorf("cell %s is not an [x, y] pair", data)
+	}
+	i.X, i.Y = xy[0], xy[1]
+	return nil
+}
+
+func (r Region) MarshalJSON() ([]byte, error) {
+	return json.Marshal(regionJSON{r.op, r.result, r.indices.SortedSlice()})
+}
+
+func (r *Region) UnmarshalJSON(data []byte) error {
+	var rj regionJSON
+	if err := json.Unmarshal(data, &rj); err != nil {
+		return err
+	}
+	*r = *NewRegion(rj.Op, rj.Result, rj.Cells...)
+	return nil
+}
+
+// MarshalJSON encodes the puzzle's regions, along with its values if any have
+// been set.
+func (p Puzzle) MarshalJSON() ([]byte, error) {
+	hasValues := false
+	for y := range p.puzzle {
+		for x := range p.puzzle[y] {
+			hasValues = hasValues || p.puzzle[y][x].IsValueSet()
+		}
+	}
+	return p.MarshalJSONWithOptions(JSONOptions{Values: hasValues})
+}
+
+func (p *Puzzle) MarshalJSONWithOptions(opts JSONOptions) ([]byte, error) {
+	pj := puzzleJSON{Size: p.size, Regions: p.regions}
+	if pj.Regions == nil {
+		pj.Regions = make([]Region, 0)
+	}
+	if opts.Values {
+		pj.Values = make([][]uint, p.size)
+		for y, row := range p.Grid() {
+			pj.Values[y] = make([]uint, p.size)
+			for x, v := range row {
+				pj.Values[y][x] = uint(v)
+			}
+		}
+	}
+	if opts.Candidates {
+		pj.Candidates = make([][][]uint, p.size)
+		for y := range p.puzzle {
+			pj.Candidates[y] = make([][]uint, p.size)
+			for x, box := range p.puzzle[y] {
+				pj.Candidates[y][x] = make([]uint, 0)
+				if box.IsValueSet() {
+					continue
+				}
+				for v := uint8(1); v <= p.size; v++ {
+					if box.HasPossible(v) {
+						pj.Candidates[y][x] = append(pj.Candidates[y][x], uint(v))
+					}
+				}
+			}
+		}
+	}
+	return json.Marshal(pj)
+}
+
+// UnmarshalJSON builds the puzzle from its regions, then restores any values
+// and candidates in the encoding. Values that break a row, column or region
+// are rejected with a ValidationError.
+func (p *Puzzle) UnmarshalJSON(data []byte) error {
+	var pj puzzleJSON
+	if err := json.Unmarshal(data, &pj); err != nil {
+		return err
+	}
+	b := NewPuzzleBuilder(pj.Size)
+	b.regions = pj.Regions
+	pzl, err := b.Build()
+	if err != nil {
+		return err
+	}
+	if pj.Values != nil {
+		if err = checkGridShape(pj.Size, len(pj.Values), func(y int) int { return len(pj.Values[y]) }); err != nil {
+			return fmt.Errorf("values: %v", err)
+		}
+		grid := make([][]uint8, pj.Size)
+		for y, row := range pj.Values {
+			grid[y] = make([]uint8, pj.Size)
+			for x, v := range row {
+				if v > uint(pj.Size) {
+					return fmt.Errorf("values: %v at %v is larger than the puzzle", v, Index{uint8(x), uint8(y)})
+				}
+				grid[y][x] = uint8(v)
+			}
+		}
+		if defects := pzl.gridDefects(grid); len(defects) > 0 {
+			return ValidationError{defects}
+		}
+		for y, row := range grid {
+			for x, v := range row {
+				if v != 0 {
+					pzl.place(Index{uint8(x), uint8(y)}, v)
+				}
+			}
+		}
+	}
+	if pj.Candidates != nil {
+		if err = checkGridShape(pj.Size, len(pj.Candidates), func(y int) int { return len(pj.Candidates[y]) }); err != nil {
+			return fmt.Errorf("candidates: %v", err)
+		}
+		for y, row := range pj.Candidates {
+			for x, candidates := range row {
+				box := &pzl.puzzle[y][x]
+				if box.IsValueSet() {
+					continue
+				}
+				box.possibles = 0
+				for _, v := range candidates {
+					if v < 1 || v > uint(pj.Size) {
+						return fmt.Errorf("candidates: %v at %v is not a valid value", v, Index{uint8(x), uint8(y)})
+					}
+					box.AddPossible(uint8(v))
+				}
+			}
+		}
+		heap.Init(&pzl.heap)
+	}
+	*p = *pzl
+	return nil
+}
+
+func checkGridShape(size uint8, rows int, cols func(int) int) error {
+	if rows != int(size) {
+		return fmt.Errorf("found %v rows, expected %v", rows, size)
+	}
+	for y := 0; y < rows; y++ {
+		if cols(y) != int(size) {
+			return fmt.Errorf("row %v has %v columns, expected %v", y, cols(y), size)
+		}
+	}
+	return nil
+}
+
+// Grid returns the puzzle's values indexed [y][x], with 0 for unsolved boxes.
+func (p *Puzzle) Grid() [][]uint8 {
+	grid := make([][]uint8, p.size)
+	for y := range p.puzzle {
+		grid[y] = make([]uint8, p.size)
+		for x := range p.puzzle[y] {
+			grid[y][x] = p.puzzle[y][x].GetValue()
+		}
+	}
+	return grid
+}
+
+// place sets the value at i as if it had been chosen by the solver: the box
+// leaves the heap and v stops being possible in the rest of its row and
+// column.
+func (p *Puzzle) place(i Index, v uint8) {
+	box := p.getBox(i)
+	if box.heapIndex >= 0 {
+		heap.Remove(&p.heap, box.heapIndex)
+	}
+	box.SetValue(v)
+	p.deletePossibilityFromRow(v, i.Y)
+	p.deletePossibilityFromCol(v, i.X)
+}
@@ -0,0 +1,146 @@
+package kenken
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const smallPuzzleJSON = `{"size":2,"regions":[` +
+	`{"op":"-","result":1,"cells":[[0,0],[1,0]]},` +
+	`{"op":"=","result":2,"cells":[[0,1]]},` +
+	`{"op":"+","result":1,"cells":[[1,1]]}]}`
+
+func TestOperationJSON(t *testing.T) {
+	ops := []Operation{Sum, Sub, Mul, Div, Nothing}
+	encoded, err := json.Marshal(ops)
+	if err != nil {
+		t.Fatalf("Marshal failed with error: %v", err)
+	}
+	if string(encoded) != `["+","-","*","/","="]` {
+		t.Errorf("Operations encoded as %s", encoded)
+	}
+	var decoded []Operation
+	if err = json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed with error: %v", err)
+	}
+	for i, op := range ops {
+		if decoded[i] != op {
+			t.Errorf("Decoded %v, expected %v", decoded[i], op)
+		}
+	}
+	if _, err = json.Marshal(Operation(0)); err == nil {
+		t.Errorf("Marshal accepted an unknown operation")
+	}
+	if err = json.Unmarshal([]byte(`"%"`), &decoded[0]); err == nil {
+		t.Errorf("Unmarshal accepted an unknown operation")
+	}
+}
+
+func TestPuzzleJSONRoundTrip(t *testing.T) {
+	var p Puzzle
+	if err := json.Unmarshal([]byte(smallPuzzleJSON), &p); err != nil {
+		t.Fatalf("Unmarshal failed with error: %v", err)
+	}
+	encoded, err := json.Marshal(&p)
+	if err != nil {
+		t.Fatalf("Marshal failed with error: %v", err)
+	}
+	if string(encoded) != smallPuzzleJSON {
+		t.Errorf("Round trip produced:\n%s\nexpected:\n%s", encoded, smallPuzzleJSON)
+	}
+	if err = p.Solve(); err != nil {
+		t.Fatalf("Solve failed with error: %v", err)
+	}
+	encoded, _ = json.Marshal(&p)
+	expected := strings.TrimSuffix(smallPuzzleJSON, "}") + `,"values":[[1,2],[2,1]]}`
+	if string(encoded) != expected {
+		t.Errorf("Solved puzzle encoded as:\n%s\nexpected:\n%s", encoded, expected)
+	}
+}
+
+func TestPuzzleJSONIsDeterministic(t *testing.T) {
+	p, _ := examplePuzzleBuilder().Build()
+	first, _ := p.MarshalJSONWithOptions(JSONOptions{Values: true, Candidates: true})
+	for i := 0; i < 10; i++ {
+		p, _ = examplePuzzleBuilder().Build()
+		encoded, _ := p.MarshalJSONWithOptions(JSONOptions{Values: true, Candidates: true})
+		if string(encoded) != string(first) {
+			t.Fatalf("Encodings differed:\n%s\n%s", encoded, first)
+		}
+	}
+}
+
+func TestPuzzleJSONRestoresState(t *testing.T) {
+	p, _ := examplePuzzleBuilder().Build()
+	p.place(Index{2, 3}, 3)
+	p.place(Index{0, 2}, 2)
+	encoded, err := p.MarshalJSONWithOptions(JSONOptions{Values: true, Candidates: true})
+	if err != nil {
+		t.Fatalf("Marshal failed with error: %v", err)
+	}
+
+	var decoded Puzzle
+	if err = json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed with error: %v", err)
+	}
+	if decoded.GetValue(Index{2, 3}) != 3 || decoded.GetValue(Index{0, 2}) != 2 {
+		t.Errorf("Values were not restored:\n%v", decoded.String())
+	}
+	if decoded.heap.Len() != 23 {
+		t.Errorf("Heap had %v boxes, expected %v", decoded.heap.Len(), 23)
+	}
+	for y := range p.puzzle {
+		for x := range p.puzzle[y] {
+			if p.puzzle[y][x].IsValueSet() {
+				continue
+			}
+			checkBoxPossibles(t, &decoded.puzzle[y][x], p.puzzle[y][x].GetPossibles())
+		}
+	}
+	if err = decoded.Solve(); err != nil {
+		t.Fatalf("Solve failed with error: %v", err)
+	}
+}
+
+func TestPuzzleJSONRejectsBadInput(t *testing.T) {
+	inputs := []string{
+		`{"size":2,"regions":[{"op":"+","result":3,"cells":[[0,0],[1,0]]}]}`,
+		strings.TrimSuffix(smallPuzzleJSON, "}") + `,"values":[[2,1]]}`,
+		strings.TrimSuffix(smallPuzzleJSON, "}") + `,"values":[[3,1],[2,1]]}`,
+		`{"size":2,"regions":[{"op":"x","result":3,"cells":[[0,0]]}]}`,
+		`{"size":1,"regions":[{"op":"=","result":1,"cells":[[0,0,0]]}]}`,
+		`{"size":1,"regions":[{"op":"=","result":1,"cells":[[0]]}]}`,
+	}
+	for _, input := range inputs {
+		var p Puzzle
+		if err := json.Unmarshal([]byte(input), &p); err == nil {
+			t.Errorf("Unmarshal accepted %v", input)
+		}
+	}
+}
+
+func TestPuzzleJSONRejectsInconsistentValues(t *testing.T) {
+	inputs := []string{
+		strings.TrimSuffix(smallPuzzleJSON, "}") + `,"values":[[1,1],[0,0]]}`,
+		strings.TrimSuffix(smallPuzzleJSON, "}") + `,"values":[[1,0],[1,0]]}`,
+		strings.TrimSuffix(smallPuzzleJSON, "}") + `,"values":[[0,0],[1,0]]}`,
+	}
+	for _, input := range inputs {
+		var p Puzzle
+		var invalid ValidationError
+		if err := json.Unmarshal([]byte(input), &p); !errors.As(err, &invalid) {
+			t.Errorf("Expected a ValidationError for %v, got: %v", input, err)
+		}
+	}
+}
+
+func TestPuzzleJSONByValue(t *testing.T) {
+	var p Puzzle
+	json.Unmarshal([]byte(smallPuzzleJSON), &p)
+	encoded, err := json.Marshal(p)
+	if err != nil || string(encoded) != smallPuzzleJSON {
+		t.Errorf("Puzzle value encoded as %s: %v", encoded, err)
+	}
+}
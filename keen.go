@@ -0,0 +1,258 @@
+package kenken
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Keen game IDs, as used by Simon Tatham's Portable Puzzle Collection, look
+// like "4:a_3b_,a6m12s1d2". The number before the colon is the size; any
+// other parameters after it (such as the difficulty) are ignored. The
+// description then has two parts separated by a comma.
+//
+// The first part is the cage structure. Keen numbers cells in reading order
+// from the top left, and visits the internal grid lines between horizontally
+// adjacent cells row by row, then those between vertically adjacent cells
+// column by column. Each line is either a wall between two cages or not. A
+// letter a-y stands for that many non-walls (a = 1) followed by a wall, '_'
+// for a wall alone, and 'z' for 26 non-walls with no wall. Any of these may be
+// followed by a count to repeat it, and a final virtual wall ends the list.
+//
+// The second part gives one clue per cage, in the order of each cage's first
+// cell: 'a' for addition, 's' subtraction, 'm' multiplication or 'd'
+// division, followed by the result. Single cell cages use 'a'.
+
+var keenOps = map[byte]Operation{'a': Sum, 's': Sub, 'm': Mul, 'd': Div}
+
+// ParseKeen builds a puzzle from a Keen game ID.
+func ParseKeen(id string) (*Puzzle, error) {
+	colon := strings.IndexByte(id, ':')
+	if colon < 0 {
+		return nil, fmt.Errorf("keen: game ID %q has no ':'", id)
+	}
+	params := id[:colon]
+	end := 0
+	for end < len(params) && params[end] >= '0' && params[end] <= '9' {
+		end++
+	}
+	w, err := strconv.Atoi(params[:end])
+	if err != nil || w < 1 || w > MaxSize {
+		return nil, fmt.Errorf("keen: invalid size in %q", params)
+	}
+	desc := id[colon+1:]
+	comma := strings.IndexByte(desc, ',')
+	if comma < 0 {
+		return nil, fmt.Errorf("keen: description has no clues")
+	}
+	parent, err := parseKeenBlocks(desc[:comma], w)
+	if err != nil {
+		return nil, err
+	}
+
+	cells := make(map[int][]Index)
+	roots := make([]int, 0)
+	for i := 0; i < w*w; i++ {
+		root := keenFind(parent, i)
+		if _, present := cells[root]; !present {
+			roots = append(roots, root)
+		}
+		cells[root] = append(cells[root], Index{uint8(i % w), uint8(w - 1 - i/w)})
+	}
+	sort.Ints(roots)
+
+	b := NewPuzzleBuilder(uint8(w))
+	clues := desc[comma+1:]
+	for _, root := range roots {
+		if len(clues) == 0 {
+			return nil, fmt.Errorf("keen: not enough clues for %v cages", len(roots))
+		}
+		op, ok := keenOps[clues[0]]
+		if !ok {
+			return nil, fmt.Errorf("keen: invalid clue type %q", clues[0])
+		}
+		end = 1
+		for end < len(clues) && clues[end] >= '0' && clues[end] <= '9' {
+			end++
+		}
+		result, err := strconv.ParseUint(clues[1:end], 10, 0)
+		if err != nil {
+			return nil, fmt.Errorf("keen: invalid clue %q", clues[:end])
+		}
+		clues = clues[end:]
+		if len(cells[root]) == 1 && op == Sum {
+			op = Nothing
+		}
+		b.AddCage(op, uint(result), cells[root]...)
+	}
+	if len(clues) > 0 {
+		return nil, fmt.Errorf("keen: unexpected data after clues: %q", clues)
+	}
+	return b.Build()
+}
+
+// parseKeenBlocks decodes the cage structure into a union-find forest over
+// Keen's cell numbering.
+func parseKeenBlocks(blocks string, w int) ([]int, error) {
+	parent := make([]int, w*w)
+	for i := range parent {
+		parent[i] = i
+	}
+	numLines := 2 * w * (w - 1)
+	pos := 0
+	for i := 0; i < len(blocks); {
+		c := blocks[i]
+		var run int
+		switch {
+		case c == '_':
+			run = 0
+		case c >= 'a' && c <= 'z':
+			run = int(c-'a') + 1
+		default:
+			return nil, fmt.Errorf("keen: invalid character %q in cage structure", c)
+		}
+		i++
+		end := i
+		for end < len(blocks) && blocks[end] >= '0' && blocks[end] <= '9' {
+			end++
+		}
+		repeat := 1
+		if end > i {
+			repeat, _ = strconv.Atoi(blocks[i:end])
+			i = end
+		}
+		for ; repeat > 0; repeat-- {
+			for n := 0; n < run; n++ {
+				if pos >= numLines {
+					return nil, fmt.Errorf("keen: too much data in cage structure")
+				}
+				p0, p1 := keenLineCells(pos, w)
+				keenUnion(parent, p0, p1)
+				pos++
+			}
+			if c != 'z' {
+				pos++
+				if pos > numLines+1 {
+					return nil, fmt.Errorf("keen: too much data in cage structure")
+				}
+			}
+		}
+	}
+	if pos != numLines+1 {
+		return nil, fmt.Errorf("keen: not enough data in cage structure")
+	}
+	return parent, nil
+}
+
+// keenLineCells returns the cells on either side of the nth internal line.
+func keenLineCells(n, w int) (int, int) {
+	if n < w*(w-1) {
+		y, x := n/(w-1), n%(w-1)
+		return y*w + x, y*w + x + 1
+	}
+	x, y := n/(w-1)-w, n%(w-1)
+	return y*w + x, (y+1)*w + x
+}
+
+func keenFind(parent []int, i int) int {
+	for parent[i] != i {
+		parent[i] = parent[parent[i]]
+		i = parent[i]
+	}
+	return i
+}
+
+func keenUnion(parent []int, a, b int) {
+	a, b = keenFind(parent, a), keenFind(parent, b)
+	if a > b {
+		a, b = b, a
+	}
+	parent[b] = a
+}
+
+// FormatKeen writes the puzzle as a Keen game ID. Keen only supports
+// subtraction and division on two cells, so other Sub and Div regions are
+// rejected.
+func FormatKeen(p *Puzzle) (string, error) {
+	w := int(p.size)
+	regionOf := make([]int, w*w)
+	for i := range regionOf {
+		regionOf[i] = -1
+	}
+	for r := range p.regions {
+		for _, idx := range p.regions[r].GetIndices() {
+			if idx.X >= p.size || idx.Y >= p.size {
+				return "", fmt.Errorf("keen: cell %v is outside of the puzzle", idx)
+			}
+			regionOf[(w-1-int(idx.Y))*w+int(idx.X)] = r
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%v:", w))
+	run := 0
+	lines := make([]byte, 0)
+	numLines := 2 * w * (w - 1)
+	for n := 0; n <= numLines; n++ {
+		if n < numLines {
+			p0, p1 := keenLineCells(n, w)
+			if regionOf[p0] == regionOf[p1] {
+				run++
+				continue
+			}
+		}
+		for ; run >= 26; run -= 26 {
+			lines = append(lines, 'z')
+		}
+		if run > 0 {
+			lines = append(lines, 'a'+byte(run-1))
+		} else {
+			lines = append(lines, '_')
+		}
+		run = 0
+	}
+	for i := 0; i < len(lines); {
+		end := i + 1
+		for end < len(lines) && lines[end] == lines[i] {
+			end++
+		}
+		sb.WriteByte(lines[i])
+		if end-i > 1 {
+			sb.WriteString(strconv.Itoa(end - i))
+		}
+		i = end
+	}
+
+	sb.WriteString(",")
+	seen := make(map[int]bool)
+	for _, r := range regionOf {
+		if r < 0 {
+			return "", fmt.Errorf("keen: not every cell is in a region")
+		}
+		if seen[r] {
+			continue
+		}
+		seen[r] = true
+		region := p.regions[r]
+		switch region.op {
+		case Sum, Nothing:
+			sb.WriteString("a")
+		case Mul:
+			sb.WriteString("m")
+		case Sub, Div:
+			if region.indices.Len() != 2 {
+				return "", fmt.Errorf("keen: %v regions must have two cells, found %v", region.op, region.indices.Len())
+			}
+			if region.op == Sub {
+				sb.WriteString("s")
+			} else {
+				sb.WriteString("d")
+			}
+		default:
+			return "", fmt.Errorf("keen: unknown operation %v", region.op)
+		}
+		sb.WriteString(strconv.FormatUint(uint64(region.result), 10))
+	}
+	return sb.String(), nil
+}
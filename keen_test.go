@@ -0,0 +1,97 @@
+package kenken
+
+import "testing"
+
+func TestParseKeen(t *testing.T) {
+	p, err := ParseKeen("2:a_3,a3a2a1")
+	if err != nil {
+		t.Fatalf("ParseKeen failed with error: %v", err)
+	}
+	expected := []Region{
+		*NewRegion(Sum, 3, Index{0, 1}, Index{1, 1}),
+		*NewRegion(Nothing, 2, Index{0, 0}),
+		*NewRegion(Nothing, 1, Index{1, 0}),
+	}
+	compareRegions(t, p.GetRegions(), expected)
+	if err = p.Solve(); err != nil {
+		t.Fatalf("Solve failed with error: %v", err)
+	}
+}
+
+func TestParseKeenIgnoresParameters(t *testing.T) {
+	if _, err := ParseKeen("2de:a_3,a3a2a1"); err != nil {
+		t.Errorf("ParseKeen failed with error: %v", err)
+	}
+}
+
+func TestKeenRoundTrip(t *testing.T) {
+	pzls := []Puzzle{examplePuzzle(), examplePuzzle2()}
+	for i := range pzls {
+		id, err := FormatKeen(&pzls[i])
+		if err != nil {
+			t.Fatalf("FormatKeen failed with error: %v", err)
+		}
+		p, err := ParseKeen(id)
+		if err != nil {
+			t.Fatalf("ParseKeen(%q) failed with error: %v", id, err)
+		}
+		compareRegions(t, p.GetRegions(), pzls[i].GetRegions())
+		again, _ := FormatKeen(p)
+		if again != id {
+			t.Errorf("FormatKeen gave %q after a round trip, expected %q", again, id)
+		}
+	}
+}
+
+func TestKeenLongRuns(t *testing.T) {
+	b := NewPuzzleBuilder(6)
+	for y := uint8(0); y < 6; y++ {
+		b.AddCage(Sum, 21, Index{0, y}, Index{1, y}, Index{2, y}, Index{3, y}, Index{4, y}, Index{5, y})
+	}
+	p, err := b.Build()
+	if err != nil {
+		t.Fatalf("Build failed with error: %v", err)
+	}
+	expected := "6:zd_30,a21a21a21a21a21a21"
+	id, err := FormatKeen(p)
+	if err != nil || id != expected {
+		t.Errorf("FormatKeen returned %q, %v, expected %q", id, err, expected)
+	}
+	if _, err = ParseKeen(id); err != nil {
+		t.Errorf("ParseKeen failed with error: %v", err)
+	}
+}
+
+func TestFormatKeenRejectsLargeSub(t *testing.T) {
+	b := NewPuzzleBuilder(3).AddCage(Sub, 1, Index{0, 0}, Index{1, 0}, Index{0, 1})
+	for _, idx := range []Index{{2, 0}, {1, 1}, {2, 1}, {0, 2}, {1, 2}, {2, 2}} {
+		b.AddCage(Nothing, 2, idx)
+	}
+	p, err := b.Build()
+	if err != nil {
+		t.Fatalf("Build failed with error: %v", err)
+	}
+	if _, err = FormatKeen(p); err == nil {
+		t.Errorf("FormatKeen accepted a three cell Sub region")
+	}
+}
+
+func TestParseKeenErrors(t *testing.T) {
+	ids := []string{
+		"a_3,a3a2a1",
+		"x:a_3,a3a2a1",
+		"2:a_3",
+		"2:a_2,a3a2a1",
+		"2:a_4,a3a2a1",
+		"2:a_3,a3a2",
+		"2:a_3,a3a2a1a1",
+		"2:a_3,a3x2a1",
+		"2:A_3,a3a2a1",
+		"64:_,a1",
+	}
+	for _, id := range ids {
+		if _, err := ParseKeen(id); err == nil {
+			t.Errorf("ParseKeen accepted %q", id)
+		}
+	}
+}
@@ -0,0 +1,667 @@
+package kenken
+
+import (
+	"container/heap"
+	"fmt"
+	"strings"
+)
+
+// Technique is a named deduction used by the logical solver. Techniques are
+// ordered from easiest to hardest.
+type Technique uint8
+
+const (
+	NakedSingle     Technique = 1
+	HiddenSingle    Technique = 2
+	CageCombination Technique = 3
+	NakedPair       Technique = 4
+	HiddenPair      Technique = 5
+	NakedTriple     Technique = 6
+	HiddenTriple    Technique = 7
+	InnieOutie      Technique = 8
+)
+
+// Techniques lists every technique, from easiest to hardest.
+var Techniques = []Technique{NakedSingle, HiddenSingle, CageCombination, NakedPair, HiddenPair, NakedTriple, HiddenTriple, InnieOutie}
+
+func (t Technique) String() string {
+	switch t {
+	case NakedSingle:
+		return "Naked single"
+	case HiddenSingle:
+		return "Hidden single"
+	case CageCombination:
+		return "Cage combination"
+	case NakedPair:
+		return "Naked pair"
+	case HiddenPair:
+		return "Hidden pair"
+	case NakedTriple:
+		return "Naked triple"
+	case HiddenTriple:
+		return "Hidden triple"
+	case InnieOutie:
+		return "Innie/outie"
+	default:
+		return "Unknown"
+	}
+}
+
+type HouseKind uint8
+
+const (
+	NoHouse HouseKind = 0
+	Row     HouseKind = 1
+	Column  HouseKind = 2
+)
+
+// House is a row or column of the puzzle, which must hold every value once.
+type House struct {
+	Kind HouseKind
+	Line uint8
+}
+
+func (h House) String() string {
+	switch h.Kind {
+	case Row:
+		return fmt.Sprintf("row %v", h.Line)
+	case Column:
+		return fmt.Sprintf("column %v", h.Line)
+	default:
+		return "none"
+	}
+}
+
+// Candidate is a value at a cell, either placed or ruled out by a step.
+type Candidate struct {
+	Index Index
+	Value uint8
+}
+
+// Step is a single deduction: the values it places, the candidates it rules
+// out, the region or house it's based on, and why it holds. Region is the
+// region's position in GetRegions, or -1 if the step isn't based on one.
+type Step struct {
+	Technique Technique
+	Placed    []Candidate
+	Removed   []Candidate
+	Region    int
+	House     House
+	Reason    string
+}
+
+// Cells returns every cell the step changes, in sorted order.
+func (s Step) Cells() []Index {
+	cells := *NewIndexSet()
+	for _, c := range s.Placed {
+		cells.Add(c.Index)
+	}
+	for _, c := range s.Removed {
+		cells.Add(c.Index)
+	}
+	return cells.SortedSlice()
+}
+
+// LogicResult is the outcome of solving a puzzle by deduction alone.
+type LogicResult struct {
+	Steps []Step
+	// Solved is true if the deductions filled every box without guessing.
+	Solved bool
+	// Grid holds the values found, indexed [y][x], with 0 for boxes the
+	// deductions couldn't fill.
+	Grid [][]uint8
+}
+
+// SolveLogically solves a copy of the puzzle using only the deductions in
+// Techniques, always applying the easiest one available. It stops when the
+// puzzle is solved or no technique makes progress.
+func (p *Puzzle) SolveLogically() LogicResult {
+	w := p.Clone()
+	steps := make([]Step, 0)
+	if p.Validate() == nil {
+		steps = w.deduce()
+	}
+	return LogicResult{steps, w.isSolved(), w.Grid()}
+}
+
+// NextHint returns the easiest deduction available from the current values
+// and candidates, without applying it. Candidates ruled out by a player can be
+// loaded with the puzzle's JSON. It returns false if the puzzle is solved, or
+// if no technique makes progress, and an error if the puzzle is invalid.
+func (p *Puzzle) NextHint() (Step, bool, error) {
+	if err := p.Validate(); err != nil {
+		return Step{}, false, err
+	}
+	if p.isSolved() {
+		return Step{}, false, nil
+	}
+	step, found := p.nextStep()
+	return step, found, nil
+}
+
+// deduce applies the easiest deduction available until the puzzle is solved
+// or none are left, and returns the steps taken.
+func (p *Puzzle) deduce() []Step {
+	steps := make([]Step, 0)
+	for !p.isSolved() {
+		step, ok := p.nextStep()
+		if !ok {
+			break
+		}
+		p.applyStep(step)
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+func (p *Puzzle) isSolved() bool {
+	for y := range p.puzzle {
+		for x := range p.puzzle[y] {
+			if !p.puzzle[y][x].IsValueSet() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (p *Puzzle) applyStep(s Step) {
+	for _, c := range s.Placed {
+		p.place(c.Index, c.Value)
+	}
+	for _, c := range s.Removed {
+		p.eliminate(c.Index, c.Value)
+	}
+}
+
+// eliminate rules v out for the box at i.
+func (p *Puzzle) eliminate(i Index, v uint8) {
+	box := p.getBox(i)
+	box.DeletePossible(v)
+	if box.heapIndex >= 0 {
+		heap.Fix(&p.heap, box.heapIndex)
+	}
+}
+
+// nextStep finds the easiest deduction available without changing the
+// puzzle. It returns false if there is none, or if some box has no
+// candidates left.
+func (p *Puzzle) nextStep() (Step, bool) {
+	for y := range p.puzzle {
+		for x := range p.puzzle[y] {
+			if box := p.puzzle[y][x]; !box.IsValueSet() && box.NumPossible() == 0 {
+				return Step{}, false
+			}
+		}
+	}
+	finders := []func() (Step, bool){
+		p.findNakedSingle,
+		p.findHiddenSingle,
+		p.findCageCombination,
+		func() (Step, bool) { return p.findNakedSubset(2) },
+		func() (Step, bool) { return p.findHiddenSubset(2) },
+		func() (Step, bool) { return p.findNakedSubset(3) },
+		func() (Step, bool) { return p.findHiddenSubset(3) },
+		p.findInnieOutie,
+	}
+	for _, find := range finders {
+		if step, ok := find(); ok {
+			return step, true
+		}
+	}
+	return Step{}, false
+}
+
+func (p *Puzzle) houses() []House {
+	houses := make([]House, 0, 2*int(p.size))
+	for i := uint8(0); i < p.size; i++ {
+		houses = append(houses, House{Row, i})
+	}
+	for i := uint8(0); i < p.size; i++ {
+		houses = append(houses, House{Column, i})
+	}
+	return houses
+}
+
+func (p *Puzzle) houseCells(h House) []Index {
+	cells := make([]Index, p.size)
+	for i := uint8(0); i < p.size; i++ {
+		cells[i] = h.cell(i)
+	}
+	return cells
+}
+
+// cell returns the i-th cell along the house.
+func (h House) cell(i uint8) Index {
+	if h.Kind == Row {
+		return Index{i, h.Line}
+	}
+	return Index{h.Line, i}
+}
+
+// unsolvedCells returns the cells of the house without a value.
+func (p *Puzzle) unsolvedCells(h House) []Index {
+	cells := make([]Index, 0, p.size)
+	for _, idx := range p.houseCells(h) {
+		if !p.getBox(idx).IsValueSet() {
+			cells = append(cells, idx)
+		}
+	}
+	return cells
+}
+
+// regionIndex returns the position of r in p.regions, or -1 if it isn't one
+// of them.
+func (p *Puzzle) regionIndex(r *Region) int {
+	for i := range p.regions {
+		if &p.regions[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *Puzzle) findNakedSingle() (Step, bool) {
+	for y := uint8(0); y < p.size; y++ {
+		for x := uint8(0); x < p.size; x++ {
+			box := p.getBox(Index{x, y})
+			if box.IsValueSet() || box.NumPossible() != 1 {
+				continue
+			}
+			v := box.Possibles().Min()
+			return Step{
+				Technique: NakedSingle,
+				Placed:    []Candidate{{box.idx, v}},
+				Region:    p.regionIndex(p.regionsByIndex[box.idx]),
+				Reason:    fmt.Sprintf("%v can only be %v", box.idx, v),
+			}, true
+		}
+	}
+	return Step{}, false
+}
+
+func (p *Puzzle) findHiddenSingle() (Step, bool) {
+	for _, h := range p.houses() {
+		cells := p.unsolvedCells(h)
+		for v := uint8(1); v <= p.size; v++ {
+			found := make([]Index, 0, 1)
+			for _, idx := range cells {
+				if p.getBox(idx).HasPossible(v) {
+					found = append(found, idx)
+				}
+			}
+			if len(found) != 1 || p.getBox(found[0]).NumPossible() == 1 {
+				continue
+			}
+			return Step{
+				Technique: HiddenSingle,
+				Placed:    []Candidate{{found[0], v}},
+				Region:    -1,
+				House:     h,
+				Reason:    fmt.Sprintf("%v can only go in %v in %v", v, found[0], h),
+			}, true
+		}
+	}
+	return Step{}, false
+}
+
+// regionSupport returns, for each unsolved cell of the region, the values it
+// takes in at least one way of completing the region from the current
+// candidates. Returns false if the region can't be completed at all.
+func (p *Puzzle) regionSupport(r *Region) (map[Index]PossibleSet, bool) {
+	support := make(map[Index]PossibleSet)
+	allowed := func(idx Index, v uint8) bool {
+		box := p.getBox(idx)
+		if box.IsValueSet() {
+			return box.GetValue() == v
+		}
+		return box.HasPossible(v)
+	}
+	// Once every candidate is supported, there's no need to look further.
+	unsupported := 0
+	for idx := range r.indices {
+		if box := p.getBox(idx); !box.IsValueSet() {
+			unsupported += int(box.NumPossible())
+		}
+	}
+	completed := false
+	r.forEachAssignment(p.combos(), p.size, allowed, func(cells []Index, values []uint8) bool {
+		completed = true
+		for i, idx := range cells {
+			if p.getBox(idx).IsValueSet() {
+				continue
+			}
+			set := support[idx]
+			if !set.Contains(values[i]) {
+				set.Add(values[i])
+				support[idx] = set
+				unsupported--
+			}
+		}
+		return unsupported == 0
+	})
+	return support, completed
+}
+
+func (p *Puzzle) findCageCombination() (Step, bool) {
+	for i := range p.regions {
+		r := &p.regions[i]
+		removed := make([]Candidate, 0)
+		support, _ := p.regionSupport(r)
+		for _, idx := range r.indices.SortedSlice() {
+			box := p.getBox(idx)
+			if box.IsValueSet() {
+				continue
+			}
+			set := support[idx]
+			for v := range box.Possibles().All() {
+				if !set.Contains(v) {
+					removed = append(removed, Candidate{idx, v})
+				}
+			}
+		}
+		if len(removed) == 0 {
+			continue
+		}
+		return Step{
+			Technique: CageCombination,
+			Removed:   removed,
+			Region:    p.regionIndex(r),
+			Reason: fmt.Sprintf("No way of making %v%v in the cage at %v uses %v",
+				r.result, r.op.Symbol(), formatIndices(r.indices.SortedSlice()), formatCandidates(removed)),
+		}, true
+	}
+	return Step{}, false
+}
+
+func (p *Puzzle) findNakedSubset(k int) (Step, bool) {
+	technique := NakedPair
+	if k == 3 {
+		technique = NakedTriple
+	}
+	for _, h := range p.houses() {
+		cells := p.unsolvedCells(h)
+		var step Step
+		found := forEachSubset(len(cells), k, func(chosen []int) bool {
+			union := PossibleSet(0)
+			subset := make([]Index, 0, k)
+			for _, c := range chosen {
+				box := p.getBox(cells[c])
+				if box.NumPossible() < 2 {
+					return false
+				}
+				union = union.Union(box.Possibles())
+				subset = append(subset, cells[c])
+			}
+			if union.Len() != k {
+				return false
+			}
+			removed := make([]Candidate, 0)
+			values := union.Values()
+			for _, idx := range cells {
+				if containsIndex(subset, idx) {
+					continue
+				}
+				for _, v := range values {
+					if p.getBox(idx).HasPossible(v) {
+						removed = append(removed, Candidate{idx, v})
+					}
+				}
+			}
+			if len(removed) == 0 {
+				return false
+			}
+			step = Step{
+				Technique: technique,
+				Removed:   removed,
+				Region:    -1,
+				House:     h,
+				Reason: fmt.Sprintf("%v must hold %v between them, so no other cell in %v can",
+					formatIndices(subset), formatValues(values), h),
+			}
+			return true
+		})
+		if found {
+			return step, true
+		}
+	}
+	return Step{}, false
+}
+
+func (p *Puzzle) findHiddenSubset(k int) (Step, bool) {
+	technique := HiddenPair
+	if k == 3 {
+		technique = HiddenTriple
+	}
+	for _, h := range p.houses() {
+		cells := p.unsolvedCells(h)
+		values := make([]uint8, 0, p.size)
+		for v := uint8(1); v <= p.size; v++ {
+			for _, idx := range cells {
+				if p.getBox(idx).HasPossible(v) {
+					values = append(values, v)
+					break
+				}
+			}
+		}
+		var step Step
+		found := forEachSubset(len(values), k, func(chosen []int) bool {
+			subset := PossibleSet(0)
+			for _, c := range chosen {
+				subset.Add(values[c])
+			}
+			holders := make([]Index, 0, k)
+			for _, idx := range cells {
+				if p.getBox(idx).Possibles().Intersect(subset) != 0 {
+					holders = append(holders, idx)
+				}
+			}
+			if len(holders) != k {
+				return false
+			}
+			removed := make([]Candidate, 0)
+			for _, idx := range holders {
+				for v := range p.getBox(idx).Possibles().All() {
+					if !subset.Contains(v) {
+						removed = append(removed, Candidate{idx, v})
+					}
+				}
+			}
+			if len(removed) == 0 {
+				return false
+			}
+			step = Step{
+				Technique: technique,
+				Removed:   removed,
+				Region:    -1,
+				House:     h,
+				Reason: fmt.Sprintf("%v can only go in %v in %v, so those cells can't hold anything else",
+					formatValues(subset.Values()), formatIndices(holders), h),
+			}
+			return true
+		})
+		if found {
+			return step, true
+		}
+	}
+	return Step{}, false
+}
+
+// houseArithmetic describes how the values of a house combine: every house
+// sums to 1+2+...+n and multiplies to n!.
+type houseArithmetic struct {
+	op       Operation
+	identity uint
+	combine  func(a, b uint) uint
+	// split undoes combine, returning false if a can't be split by b.
+	split func(a, b uint) (uint, bool)
+}
+
+var houseArithmetics = []houseArithmetic{
+	{Sum, 0, func(a, b uint) uint { return a + b }, func(a, b uint) (uint, bool) { return a - b, a >= b }},
+	{Mul, 1, func(a, b uint) uint { return a * b }, func(a, b uint) (uint, bool) {
+		if b == 0 || a%b != 0 {
+			return 0, false
+		}
+		return a / b, true
+	}},
+}
+
+// findInnieOutie uses the total of each house. If the cages and values inside
+// a house account for all but one cell, that cell holds the difference (an
+// innie). If the cages crossing a house cover the rest of it and stick out by
+// one unsolved cell, that cell holds the excess (an outie).
+func (p *Puzzle) findInnieOutie() (Step, bool) {
+	for _, arith := range houseArithmetics {
+		total := arith.identity
+		for v := uint(1); v <= uint(p.size); v++ {
+			total = arith.combine(total, v)
+		}
+		for _, h := range p.houses() {
+			cells := p.houseCells(h)
+			if len(p.unsolvedCells(h)) == 0 {
+				continue
+			}
+			inside := make(map[Index]bool)
+			for _, idx := range cells {
+				inside[idx] = true
+			}
+			known := arith.identity
+			unknown := make([]Index, 0)
+			crossing := make([]*Region, 0)
+			seen := make(map[*Region]bool)
+			for _, idx := range cells {
+				r := p.regionsByIndex[idx]
+				if seen[r] {
+					continue
+				}
+				if r.op == arith.op || r.op == Nothing {
+					seen[r] = true
+					isInside := true
+					for cell := range r.indices {
+						isInside = isInside && inside[cell]
+					}
+					if isInside {
+						known = arith.combine(known, r.result)
+					} else {
+						crossing = append(crossing, r)
+					}
+				} else if box := p.getBox(idx); box.IsValueSet() {
+					known = arith.combine(known, uint(box.GetValue()))
+				} else {
+					unknown = append(unknown, idx)
+				}
+			}
+
+			if len(crossing) == 0 && len(unknown) == 1 {
+				v, ok := arith.split(total, known)
+				reason := fmt.Sprintf("The cages and values in %v account for all but %v, so it must be %v", h, unknown[0], v)
+				if step, found := p.innieOutieStep(unknown[0], v, ok, h, reason); found {
+					return step, true
+				}
+				continue
+			}
+			if len(crossing) == 0 || len(unknown) > 0 {
+				continue
+			}
+			excess := known
+			for _, r := range crossing {
+				excess = arith.combine(excess, r.result)
+			}
+			excess, ok := arith.split(excess, total)
+			outies := make([]Index, 0)
+			for _, r := range crossing {
+				for _, cell := range r.indices.SortedSlice() {
+					if inside[cell] {
+						continue
+					}
+					if box := p.getBox(cell); box.IsValueSet() {
+						var splitOK bool
+						excess, splitOK = arith.split(excess, uint(box.GetValue()))
+						ok = ok && splitOK
+					} else {
+						outies = append(outies, cell)
+					}
+				}
+			}
+			if len(outies) != 1 {
+				continue
+			}
+			reason := fmt.Sprintf("The cages crossing %v cover it and stick out only at %v, so it must be %v", h, outies[0], excess)
+			if step, found := p.innieOutieStep(outies[0], excess, ok, h, reason); found {
+				return step, true
+			}
+		}
+	}
+	return Step{}, false
+}
+
+func (p *Puzzle) innieOutieStep(idx Index, v uint, ok bool, h House, reason string) (Step, bool) {
+	box := p.getBox(idx)
+	if !ok || v < 1 || v > uint(p.size) || !box.HasPossible(uint8(v)) {
+		return Step{}, false
+	}
+	return Step{
+		Technique: InnieOutie,
+		Placed:    []Candidate{{idx, uint8(v)}},
+		Region:    -1,
+		House:     h,
+		Reason:    reason,
+	}, true
+}
+
+// forEachSubset calls fn with every k sized subset of 0..n-1 in increasing
+// order, until fn returns true. Returns whether fn did.
+func forEachSubset(n, k int, fn func([]int) bool) bool {
+	chosen := make([]int, k)
+	var choose func(start, depth int) bool
+	choose = func(start, depth int) bool {
+		if depth == k {
+			return fn(chosen)
+		}
+		for i := start; i <= n-(k-depth); i++ {
+			chosen[depth] = i
+			if choose(i+1, depth+1) {
+				return true
+			}
+		}
+		return false
+	}
+	return choose(0, 0)
+}
+
+func containsIndex(s []Index, i Index) bool {
+	for _, idx := range s {
+		if idx == i {
+			return true
+		}
+	}
+	return false
+}
+
+func formatIndices(s []Index) string {
+	strs := make([]string, len(s))
+	for i, idx := range s {
+		strs[i] = idx.String()
+	}
+	return strings.Join(strs, ",")
+}
+
+func formatValues(s []uint8) string {
+	strs := make([]string, len(s))
+	for i, v := range s {
+		strs[i] = fmt.Sprint(v)
+	}
+	if len(strs) <= 1 {
+		return strings.Join(strs, "")
+	}
+	return strings.Join(strs[:len(strs)-1], ", ") + " and " + strs[len(strs)-1]
+}
+
+func formatCandidates(s []Candidate) string {
+	strs := make([]string, len(s))
+	for i, c := range s {
+		strs[i] = fmt.Sprintf("%v at %v", c.Value, c.Index)
+	}
+	return strings.Join(strs, ", ")
+}
@@ -0,0 +1,213 @@
+package kenken
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestSolveLogically(t *testing.T) {
+	builders := []*PuzzleBuilder{examplePuzzleBuilder(), examplePuzzle2Builder()}
+	sols := [][][]uint8{exampleSolution(), exampleSolution2()}
+	for i, b := range builders {
+		p, _ := b.Build()
+		result := p.SolveLogically()
+		if !result.Solved {
+			t.Errorf("Example %v was not solved logically after %v steps", i, len(result.Steps))
+		}
+		if !sameGrid(result.Grid, sols[i]) {
+			t.Errorf("Example %v was solved as %v, expected %v", i, result.Grid, sols[i])
+		}
+		checkStepsAreSound(t, result.Steps, sols[i])
+		if p.GetValue(Index{0, 0}) != 0 {
+			t.Errorf("SolveLogically modified the puzzle")
+		}
+	}
+}
+
+func TestSolveLogicallyGenerated(t *testing.T) {
+	for size := uint8(3); size <= 6; size++ {
+		for seed := int64(0); seed < 5; seed++ {
+			p, _ := Generate(GeneratorOptions{Size: size, Seed: seed})
+			p.Solve()
+			solution := p.Grid()
+			p, _ = Generate(GeneratorOptions{Size: size, Seed: seed})
+			result := p.SolveLogically()
+			checkStepsAreSound(t, result.Steps, solution)
+			if result.Solved && !sameGrid(result.Grid, solution) {
+				t.Errorf("Logical solution %v differs from %v", result.Grid, solution)
+			}
+		}
+	}
+}
+
+func TestSolveLogicallyStalls(t *testing.T) {
+	p, _ := NewPuzzleBuilder(3).
+		AddCage(Sum, 6, Index{0, 0}, Index{1, 0}, Index{2, 0}).
+		AddCage(Sum, 6, Index{0, 1}, Index{1, 1}, Index{2, 1}).
+		AddCage(Sum, 6, Index{0, 2}, Index{1, 2}, Index{2, 2}).
+		Build()
+	result := p.SolveLogically()
+	if result.Solved || len(result.Steps) != 0 {
+		t.Errorf("Ambiguous puzzle was solved logically in %v steps", len(result.Steps))
+	}
+}
+
+func TestFindInnie(t *testing.T) {
+	p, _ := NewPuzzleBuilder(3).
+		AddCage(Sum, 3, Index{0, 0}, Index{1, 0}).
+		AddCage(Mul, 6, Index{2, 0}, Index{2, 1}).
+		AddCage(Sum, 10, Index{0, 1}, Index{1, 1}, Index{0, 2}, Index{1, 2}, Index{2, 2}).
+		Build()
+	step, ok := p.findInnieOutie()
+	if !ok || len(step.Placed) != 1 || step.Placed[0] != (Candidate{Index{2, 0}, 3}) {
+		t.Errorf("Expected an innie placing 3 at (2,0), got: %+v", step)
+	}
+	if step.House != (House{Row, 0}) {
+		t.Errorf("Innie was in %v, expected row 0", step.House)
+	}
+}
+
+func TestFindOutie(t *testing.T) {
+	p, _ := NewPuzzleBuilder(3).
+		AddCage(Sum, 5, Index{0, 0}, Index{1, 0}, Index{0, 1}).
+		AddCage(Nothing, 3, Index{2, 0}).
+		AddCage(Nothing, 3, Index{1, 1}).
+		AddCage(Nothing, 1, Index{2, 1}).
+		AddCage(Nothing, 3, Index{0, 2}).
+		AddCage(Nothing, 1, Index{1, 2}).
+		AddCage(Nothing, 2, Index{2, 2}).
+		Build()
+	step, ok := p.findInnieOutie()
+	if !ok || len(step.Placed) != 1 || step.Placed[0] != (Candidate{Index{0, 1}, 2}) {
+		t.Errorf("Expected an outie placing 2 at (0,1), got: %+v", step)
+	}
+}
+
+func TestFindSubsets(t *testing.T) {
+	p := NewPuzzle(4)
+	for y := uint8(0); y < 4; y++ {
+		for x := uint8(0); x < 4; x++ {
+			p.puzzle[y][x] = *NewBox(Index{x, y}, 4)
+			for v := uint8(1); v <= 4; v++ {
+				p.puzzle[y][x].AddPossible(v)
+			}
+		}
+	}
+	p.buildHeap()
+	for v := uint8(3); v <= 4; v++ {
+		p.eliminate(Index{0, 0}, v)
+		p.eliminate(Index{1, 0}, v)
+	}
+	step, ok := p.findNakedSubset(2)
+	if !ok || step.Technique != NakedPair || step.House != (House{Row, 0}) || len(step.Removed) != 4 {
+		t.Errorf("Expected a naked pair removing 4 candidates from row 0, got: %+v", step)
+	}
+	for _, c := range step.Removed {
+		if c.Index.Y != 0 || c.Index.X < 2 || c.Value > 2 {
+			t.Errorf("Naked pair removed unexpected candidate %v", c)
+		}
+	}
+	step, ok = p.findHiddenSubset(2)
+	if !ok || step.Technique != HiddenPair || len(step.Removed) != 4 {
+		t.Errorf("Expected a hidden pair removing 4 candidates, got: %+v", step)
+	}
+}
+
+func checkStepsAreSound(t *testing.T, steps []Step, solution [][]uint8) {
+	t.Helper()
+	for _, s := range steps {
+		for _, c := range s.Placed {
+			if solution[c.Index.Y][c.Index.X] != c.Value {
+				t.Errorf("%v step placed %v at %v, solution has %v: %v", s.Technique, c.Value, c.Index, solution[c.Index.Y][c.Index.X], s.Reason)
+			}
+		}
+		for _, c := range s.Removed {
+			if solution[c.Index.Y][c.Index.X] == c.Value {
+				t.Errorf("%v step removed the solution %v at %v: %v", s.Technique, c.Value, c.Index, s.Reason)
+			}
+		}
+		if s.Reason == "" || len(s.Cells()) == 0 {
+			t.Errorf("%v step had no reason or cells: %+v", s.Technique, s)
+		}
+	}
+}
+
+// examplePuzzle2Builder describes the same puzzle as examplePuzzle2.
+func examplePuzzle2Builder() *PuzzleBuilder {
+	return NewPuzzleBuilder(5).
+		AddCage(Sub, 1, Index{0, 0}, Index{1, 0}).
+		AddCage(Sum, 9, Index{2, 0}, Index{2, 1}, Index{2, 2}).
+		AddCage(Sub, 1, Index{3, 0}, Index{4, 0}).
+		AddCage(Sum, 3, Index{0, 1}, Index{0, 2}).
+		AddCage(Mul, 12, Index{1, 1}, Index{1, 2}).
+		AddCage(Div, 2, Index{3, 1}, Index{4, 1}).
+		AddCage(Nothing, 5, Index{3, 2}).
+		AddCage(Sum, 6, Index{4, 2}, Index{4, 3}).
+		AddCage(Sub, 2, Index{0, 3}, Index{0, 4}).
+		AddCage(Div, 2, Index{1, 3}, Index{1, 4}).
+		AddCage(Sub, 2, Index{2, 3}, Index{2, 4}).
+		AddCage(Sub, 2, Index{3, 3}, Index{3, 4}).
+		AddCage(Nothing, 4, Index{4, 4})
+}
+
+func TestStepRegions(t *testing.T) {
+	p, _ := examplePuzzleBuilder().Build()
+	regions := p.GetRegions()
+	for _, step := range p.SolveLogically().Steps {
+		if step.Technique != NakedSingle && step.Technique != CageCombination {
+			if step.Region != -1 {
+				t.Errorf("%v step has region %v", step.Technique, step.Region)
+			}
+			continue
+		}
+		if step.Region < 0 || step.Region >= len(regions) {
+			t.Fatalf("%v step has region %v, expected one of %v", step.Technique, step.Region, len(regions))
+		}
+		cells := regions[step.Region].GetIndices()
+		for _, c := range step.Cells() {
+			if !slices.Contains(cells, c) {
+				t.Errorf("%v step changes %v, outside of its region %v", step.Technique, c, regions[step.Region])
+			}
+		}
+	}
+}
+
+func TestNextHint(t *testing.T) {
+	p, _ := examplePuzzleBuilder().Build()
+	hint, ok, err := p.NextHint()
+	if err != nil {
+		t.Fatalf("NextHint failed: %v", err)
+	}
+	first := p.SolveLogically().Steps[0]
+	if !ok || hint.Technique != first.Technique || hint.Reason != first.Reason {
+		t.Errorf("Expected the first logical step %+v, got: %+v", first, hint)
+	}
+	if p.GetValue(Index{0, 0}) != 0 {
+		t.Errorf("NextHint modified the puzzle")
+	}
+
+	data := []byte(`{"size":2,"regions":[{"op":"+","result":3,"cells":[[0,0],[1,0]]},{"op":"+","result":3,"cells":[[0,1],[1,1]]}],"candidates":[[[1],[1,2]],[[1,2],[1,2]]]}`)
+	p = new(Puzzle)
+	if err := p.UnmarshalJSON(data); err != nil {
+		t.Fatalf("Failed to load puzzle: %v", err)
+	}
+	hint, ok, err = p.NextHint()
+	if err != nil || !ok || hint.Technique != NakedSingle || len(hint.Placed) != 1 || hint.Placed[0] != (Candidate{Index{0, 0}, 1}) {
+		t.Errorf("Expected a naked single at (0,0) from the candidates, got: %+v, %v", hint, err)
+	}
+
+	if err := p.Solve(); err != nil {
+		t.Fatalf("Failed to solve puzzle: %v", err)
+	}
+	if hint, ok, err = p.NextHint(); ok || err != nil {
+		t.Errorf("Solved puzzle gave hint: %+v, %v", hint, err)
+	}
+}
+
+func TestNextHintInvalid(t *testing.T) {
+	var validation ValidationError
+	if _, ok, err := NewPuzzle(4).NextHint(); ok || !errors.As(err, &validation) {
+		t.Errorf("Expected a ValidationError for a puzzle without regions, got: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package kenken
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// miniZincModel is the same for every puzzle; the data file describes the
+// cages. Rows are numbered from 1 at the top, as in the text format, and
+// cells are numbered in reading order from 1. Sub and Div cages with more
+// than two cells work as in CombinationTable.Maps.
+const miniZincModel = `% Kenken model. Pair it with a data file written for one puzzle.
+include "alldifferent.mzn";
+
+int: n;
+int: num_cages;
+int: max_cage;
+set of int: CAGE = 1..num_cages;
+
+% 1 is +, 2 is -, 3 is *, 4 is / and 5 is a single given value.
+array[CAGE] of 1..5: cage_op;
+array[CAGE] of int: cage_result;
+array[CAGE] of 1..max_cage: cage_size;
+% The cells of each cage, numbered from 1 in reading order and padded with 0.
+array[CAGE, 1..max_cage] of 0..n*n: cage_cells;
+% Values already known, or 0.
+array[1..n, 1..n] of 0..n: given;
+
+array[1..n, 1..n] of var 1..n: grid;
+
+function var int: cell(int: k) = grid[(k - 1) div n + 1, (k - 1) mod n + 1];
+
+constraint forall(r in 1..n)(alldifferent([grid[r, c] | c in 1..n]));
+constraint forall(c in 1..n)(alldifferent([grid[r, c] | r in 1..n]));
+constraint forall(r, c in 1..n where given[r, c] > 0)(grid[r, c] = given[r, c]);
+
+constraint forall(k in CAGE)(
+  let {
+    int: m = cage_size[k];
+    array[1..m] of var int: v = [cell(cage_cells[k, j]) | j in 1..m];
+  } in
+  if cage_op[k] = 1 then
+    sum(v) = cage_result[k]
+  elseif cage_op[k] = 2 then
+    exists(j in 1..m)(2 * v[j] - sum(v) = cage_result[k])
+  elseif cage_op[k] = 3 then
+    product(v) = cage_result[k]
+  elseif cage_op[k] = 4 then
+    exists(j in 1..m)(v[j] = cage_result[k] * product([v[i] | i in 1..m where i != j]))
+  else
+    v[1] = cage_result[k]
+  endif
+);
+
+solve satisfy;
+
+output [show(grid[r, c]) ++ if c = n then "\n" else " " endif | r, c in 1..n];
+`
+
+// miniZincCell returns the number of the cell at i in the MiniZinc model.
+func miniZincCell(i Index, size uint8) int {
+	return (int(size)-1-int(i.Y))*int(size) + int(i.X) + 1
+}
+
+// WriteMiniZinc writes a MiniZinc model to model and the puzzle's data to
+// data, ready to run with "minizinc model.mzn data.dzn". The model is the
+// same for every puzzle. The operations are numbered as in Operation.
+func WriteMiniZinc(model, data io.Writer, p *Puzzle) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(model, miniZincModel); err != nil {
+		return err
+	}
+	maxCage := 1
+	for i := range p.regions {
+		maxCage = max(maxCage, p.regions[i].indices.Len())
+	}
+	ops := make([]string, len(p.regions))
+	results := make([]string, len(p.regions))
+	sizes := make([]string, len(p.regions))
+	cells := make([]string, len(p.regions))
+	for i := range p.regions {
+		r := &p.regions[i]
+		ops[i] = fmt.Sprint(uint8(r.op))
+		results[i] = fmt.Sprint(r.result)
+		sizes[i] = fmt.Sprint(r.indices.Len())
+		row := make([]string, maxCage)
+		for k := range row {
+			row[k] = "0"
+		}
+		for k, c := range r.indices.SortedSlice() {
+			row[k] = fmt.Sprint(miniZincCell(c, p.size))
+		}
+		cells[i] = strings.Join(row, ", ")
+	}
+	given := make([]string, p.size)
+	for y := int(p.size) - 1; y >= 0; y-- {
+		row := make([]string, p.size)
+		for x := range row {
+			row[x] = fmt.Sprint(p.GetValue(Index{uint8(x), uint8(y)}))
+		}
+		given[int(p.size)-1-y] = strings.Join(row, ", ")
+	}
+
+	bw := bufio.NewWriter(data)
+	fmt.Fprintf(bw, "n = %v;\n", p.size)
+	fmt.Fprintf(bw, "num_cages = %v;\n", len(p.regions))
+	fmt.Fprintf(bw, "max_cage = %v;\n", maxCage)
+	fmt.Fprintf(bw, "cage_op = [%v];\n", strings.Join(ops, ", "))
+	fmt.Fprintf(bw, "cage_result = [%v];\n", strings.Join(results, ", "))
+	fmt.Fprintf(bw, "cage_size = [%v];\n", strings.Join(sizes, ", "))
+	fmt.Fprintf(bw, "cage_cells = [|\n  %v\n|];\n", strings.Join(cells, " |\n  "))
+	fmt.Fprintf(bw, "given = [|\n  %v\n|];\n", strings.Join(given, " |\n  "))
+	return bw.Flush()
+}
@@ -0,0 +1,46 @@
+package kenken
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteMiniZinc(t *testing.T) {
+	p, _ := NewPuzzleBuilder(3).
+		AddCage(Mul, 6, Index{0, 2}, Index{1, 2}, Index{0, 1}).
+		AddCage(Div, 3, Index{2, 2}, Index{2, 1}).
+		AddCage(Sub, 1, Index{1, 1}, Index{1, 0}).
+		AddCage(Sum, 2, Index{0, 0}).
+		AddCage(Nothing, 1, Index{2, 0}).
+		Build()
+	p.place(Index{2, 0}, 1)
+	var model, data strings.Builder
+	if err := WriteMiniZinc(&model, &data, p); err != nil {
+		t.Fatalf("WriteMiniZinc failed: %v", err)
+	}
+	if model.String() != miniZincModel {
+		t.Errorf("Wrote an unexpected model:\n%v", model.String())
+	}
+	expected := `n = 3;
+num_cages = 5;
+max_cage = 3;
+cage_op = [3, 4, 2, 1, 5];
+cage_result = [6, 3, 1, 2, 1];
+cage_size = [3, 2, 2, 1, 1];
+cage_cells = [|
+  4, 1, 2 |
+  6, 3, 0 |
+  8, 5, 0 |
+  7, 0, 0 |
+  9, 0, 0
+|];
+given = [|
+  0, 0, 0 |
+  0, 0, 0 |
+  0, 0, 1
+|];
+`
+	if data.String() != expected {
+		t.Errorf("Wrote data:\n%v\nexpected:\n%v", data.String(), expected)
+	}
+}
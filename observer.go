@@ -0,0 +1,63 @@
+package kenken
+
+// SolveObserver is told about each step of the backtracking search. It lets
+// callers trace, visualize or measure a solve without changing it.
+type SolveObserver interface {
+	// EnterNode is called each time the search recurses, with the number of
+	// boxes already filled.
+	EnterNode(depth int)
+	// SetValue is called when the search tries v in the box at i.
+	SetValue(i Index, v uint8)
+	// DeleteCandidate is called when setting a value rules v out for the box
+	// at i, because it shares a row or column.
+	DeleteCandidate(i Index, v uint8)
+	// RejectRegion is called when v can't go in the box at i because its
+	// region could then not be completed.
+	RejectRegion(i Index, v uint8, r *Region)
+	// Backtrack is called when the search takes v back out of the box at i
+	// and restores the candidates it ruled out.
+	Backtrack(i Index, v uint8)
+}
+
+// NoopObserver ignores every event. Embed it to implement only some of
+// SolveObserver.
+type NoopObserver struct{}
+
+func (NoopObserver) EnterNode(depth int)                      {}
+func (NoopObserver) SetValue(i Index, v uint8)                {}
+func (NoopObserver) DeleteCandidate(i Index, v uint8)         {}
+func (NoopObserver) RejectRegion(i Index, v uint8, r *Region) {}
+func (NoopObserver) Backtrack(i Index, v uint8)               {}
+
+// multiObserver passes each event to several observers in turn.
+type multiObserver []SolveObserver
+
+func (m multiObserver) EnterNode(depth int) {
+	for _, o := range m {
+		o.EnterNode(depth)
+	}
+}
+
+func (m multiObserver) SetValue(i Index, v uint8) {
+	for _, o := range m {
+		o.SetValue(i, v)
+	}
+}
+
+func (m multiObserver) DeleteCandidate(i Index, v uint8) {
+	for _, o := range m {
+		o.DeleteCandidate(i, v)
+	}
+}
+
+func (m multiObserver) RejectRegion(i Index, v uint8, r *Region) {
+	for _, o := range m {
+		o.RejectRegion(i, v, r)
+	}
+}
+
+func (m multiObserver) Backtrack(i Index, v uint8) {
+	for _, o := range m {
+		o.Backtrack(i, v)
+	}
+}
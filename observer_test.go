@@ -0,0 +1,60 @@
+package kenken
+
+import (
+	"strings"
+	"testing"
+)
+
+type countingObserver struct {
+	NoopObserver
+	nodes, sets, deletes, rejects, backtracks int
+	maxDepth                                  int
+}
+
+func (o *countingObserver) EnterNode(depth int) {
+	o.nodes++
+	o.maxDepth = max(o.maxDepth, depth)
+}
+
+func (o *countingObserver) SetValue(i Index, v uint8)                { o.sets++ }
+func (o *countingObserver) DeleteCandidate(i Index, v uint8)         { o.deletes++ }
+func (o *countingObserver) RejectRegion(i Index, v uint8, r *Region) { o.rejects++ }
+func (o *countingObserver) Backtrack(i Index, v uint8)               { o.backtracks++ }
+
+func TestSolveObserver(t *testing.T) {
+	p, _ := examplePuzzleBuilder().Build()
+	o := &countingObserver{}
+	other := &countingObserver{}
+	if err := p.Solve(o, other); err != nil {
+		t.Fatalf("Failed to solve puzzle: %v", err)
+	}
+	if o.nodes == 0 || o.sets == 0 || o.deletes == 0 {
+		t.Errorf("Observer missed events: %+v", o)
+	}
+	if o.sets-o.backtracks != 25 {
+		t.Errorf("Expected 25 values left set, got %v sets and %v backtracks", o.sets, o.backtracks)
+	}
+	if o.nodes != o.sets+1 {
+		t.Errorf("Expected a node for each value set plus the root, got %v nodes and %v sets", o.nodes, o.sets)
+	}
+	if o.maxDepth != 25 {
+		t.Errorf("Expected a maximum depth of 25, got %v", o.maxDepth)
+	}
+	if *o != *other {
+		t.Errorf("Observers saw different events: %+v and %+v", o, other)
+	}
+}
+
+func TestSolveObserverRejections(t *testing.T) {
+	p, _ := Parse(strings.NewReader(backtrackingText))
+	o := &countingObserver{}
+	if err := p.Solve(o); err == nil {
+		t.Fatalf("Solved an unsolveable puzzle")
+	}
+	if o.rejects == 0 && o.backtracks == 0 {
+		t.Errorf("Observer saw no failures: %+v", o)
+	}
+	if o.sets != o.backtracks {
+		t.Errorf("Expected every value to be taken back, got %v sets and %v backtracks", o.sets, o.backtracks)
+	}
+}
@@ -0,0 +1,194 @@
+package kenken
+
+import (
+	"container/heap"
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ParallelOptions controls SolveParallel and CountSolutionsParallel. The zero
+// value uses every CPU.
+type ParallelOptions struct {
+	// Workers is the number of goroutines searching at once. Defaults to
+	// GOMAXPROCS.
+	Workers int
+	// SplitDepth is the number of boxes filled before the search is split
+	// into branches. Defaults to 2.
+	SplitDepth int
+}
+
+func (o ParallelOptions) withDefaults() ParallelOptions {
+	if o.Workers < 1 {
+		o.Workers = runtime.GOMAXPROCS(0)
+	}
+	if o.SplitDepth < 1 {
+		o.SplitDepth = 2
+	}
+	return o
+}
+
+// Parallel is a Solver that runs SolveParallel.
+type Parallel struct {
+	Options ParallelOptions
+}
+
+func (s Parallel) Solve(ctx context.Context, p *Puzzle) (Result, error) {
+	stats, err := p.SolveParallel(ctx, s.Options)
+	return Result{Solver: "parallel", Stats: stats}, err
+}
+
+func (s Parallel) Count(ctx context.Context, p *Puzzle, limit int) (int, error) {
+	return p.CountSolutionsParallel(ctx, limit, s.Options)
+}
+
+// SolveParallel fills in the puzzle's solution, searching independent
+// branches on several goroutines. The first solution found cancels the other
+// branches. The stats are summed over every branch, except for MaxDepth and
+// Duration.
+func (p *Puzzle) SolveParallel(ctx context.Context, opts ParallelOptions) (SolveStats, error) {
+	if err := p.Validate(); err != nil {
+		return SolveStats{}, err
+	}
+	start := time.Now()
+	var mu sync.Mutex
+	var solved *Puzzle
+	stats, err := p.searchParallel(ctx, opts.withDefaults(), func(b *Puzzle) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if solved == nil {
+			solved = b.Clone()
+		}
+		return true
+	})
+	stats.Duration = time.Since(start)
+	if solved != nil {
+		p.puzzle, p.heap = solved.puzzle, solved.heap
+		return stats, nil
+	}
+	if err != nil {
+		return stats, StoppedError{err, stats}
+	}
+	return stats, UnsolveableError{stats.FailedPaths}
+}
+
+// CountSolutionsParallel is CountSolutions, with the branches of the search
+// counted on several goroutines.
+func (p *Puzzle) CountSolutionsParallel(ctx context.Context, limit int, opts ParallelOptions) (int, error) {
+	if err := p.Validate(); err != nil {
+		return 0, err
+	}
+	var count atomic.Int64
+	stats, err := p.searchParallel(ctx, opts.withDefaults(), func(*Puzzle) bool {
+		n := count.Add(1)
+		return limit > 0 && n >= int64(limit)
+	})
+	n := int(count.Load())
+	if limit > 0 && n > limit {
+		n = limit
+	}
+	if err != nil && (limit == 0 || n < limit) {
+		return n, StoppedError{err, stats}
+	}
+	return n, nil
+}
+
+// searchParallel fills the first few boxes of a copy of the puzzle, then
+// searches each branch on its own copy. visit is called from the workers
+// with the solved branch, and stops every branch by returning true. Returns
+// the context's error if it was done before the search finished.
+func (p *Puzzle) searchParallel(ctx context.Context, opts ParallelOptions, visit func(*Puzzle) bool) (SolveStats, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var found atomic.Bool
+
+	branches := make(chan *Puzzle)
+	results := make(chan SolveStats)
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range branches {
+				b.run = &solveRun{ctx: ctx}
+				_, failed := b.search(func() bool {
+					if visit(b) {
+						found.Store(true)
+						cancel()
+						return true
+					}
+					return false
+				})
+				b.run.stats.FailedPaths += failed
+				results <- b.run.stats
+			}
+		}()
+	}
+	var splitFailures uint
+	go func() {
+		root := p.Clone()
+		root.startPropagation()
+		root.split(opts.SplitDepth, &splitFailures, func(b *Puzzle) bool {
+			select {
+			case branches <- b:
+				return false
+			case <-ctx.Done():
+				return true
+			}
+		})
+		close(branches)
+		wg.Wait()
+		close(results)
+	}()
+
+	var stats SolveStats
+	for s := range results {
+		stats.add(s)
+	}
+	stats.FailedPaths += splitFailures
+	if !found.Load() {
+		return stats, ctx.Err()
+	}
+	return stats, nil
+}
+
+// split fills depth boxes in every valid way, in the same order as search,
+// and passes a copy of each partial board to branch. Values whose
+// propagation fails are counted in failed. Stops early if branch returns
+// true.
+func (p *Puzzle) split(depth int, failed *uint, branch func(*Puzzle) bool) bool {
+	if !p.propagate() {
+		*failed++
+		return false
+	}
+	if depth == 0 || p.heap.Len() == 0 {
+		return branch(p.Clone())
+	}
+	topBox := heap.Pop(&p.heap).(*Box)
+	defer heap.Push(&p.heap, topBox)
+	for v := range topBox.Possibles().All() {
+		mark := len(p.prop.trail)
+		p.assign(topBox, v)
+		stopped := p.split(depth-1, failed, branch)
+		p.undo(mark)
+		topBox.UnsetValue()
+		if stopped {
+			return true
+		}
+	}
+	return false
+}
+
+// add sums the counts of o into s, keeping the larger MaxDepth.
+func (s *SolveStats) add(o SolveStats) {
+	s.Nodes += o.Nodes
+	s.ValuesTried += o.ValuesTried
+	s.RegionRejections += o.RegionRejections
+	s.Eliminations += o.Eliminations
+	s.Backtracks += o.Backtracks
+	s.MaxDepth = max(s.MaxDepth, o.MaxDepth)
+	s.HeapOps += o.HeapOps
+	s.FailedPaths += o.FailedPaths
+}
@@ -0,0 +1,63 @@
+package kenken
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSolveParallel(t *testing.T) {
+	builders := []*PuzzleBuilder{examplePuzzleBuilder(), examplePuzzle2Builder()}
+	sols := [][][]uint8{exampleSolution(), exampleSolution2()}
+	for i, b := range builders {
+		for _, opts := range []ParallelOptions{{}, {Workers: 1, SplitDepth: 1}, {Workers: 3, SplitDepth: 30}} {
+			p, _ := b.Build()
+			stats, err := p.SolveParallel(context.Background(), opts)
+			if err != nil {
+				t.Fatalf("Failed to solve example %v with %+v: %v", i, opts, err)
+			}
+			if !sameGrid(p.Grid(), sols[i]) {
+				t.Errorf("Example %v was solved as %v, expected %v", i, p.Grid(), sols[i])
+			}
+			if stats.Nodes == 0 {
+				t.Errorf("Expected stats from the branches, got: %+v", stats)
+			}
+		}
+	}
+}
+
+func TestSolveParallelUnsolveable(t *testing.T) {
+	p, _ := NewPuzzleBuilder(2).
+		AddCage(Nothing, 1, Index{0, 0}).
+		AddCage(Nothing, 1, Index{1, 0}).
+		AddCage(Sum, 3, Index{0, 1}, Index{1, 1}).
+		Build()
+	var unsolveable UnsolveableError
+	if _, err := p.SolveParallel(context.Background(), ParallelOptions{}); !errors.As(err, &unsolveable) {
+		t.Errorf("Expected an UnsolveableError, got: %v", err)
+	}
+
+	p, _ = examplePuzzleBuilder().Build()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.SolveParallel(ctx, ParallelOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled solve, got: %v", err)
+	}
+}
+
+func TestCountSolutionsParallel(t *testing.T) {
+	p, _ := NewPuzzleBuilder(3).
+		AddCage(Sum, 6, Index{0, 0}, Index{1, 0}, Index{2, 0}).
+		AddCage(Sum, 6, Index{0, 1}, Index{1, 1}, Index{2, 1}).
+		AddCage(Sum, 6, Index{0, 2}, Index{1, 2}, Index{2, 2}).
+		Build()
+	expected, _ := p.CountSolutions(0)
+	for _, opts := range []ParallelOptions{{}, {Workers: 2, SplitDepth: 1}, {Workers: 4, SplitDepth: 9}} {
+		if count, err := p.CountSolutionsParallel(context.Background(), 0, opts); count != expected || err != nil {
+			t.Errorf("Counted %v solutions with %+v, expected %v: %v", count, opts, expected, err)
+		}
+		if count, err := p.CountSolutionsParallel(context.Background(), 2, opts); count != 2 || err != nil {
+			t.Errorf("Counted %v solutions with a limit of 2, expected 2: %v", count, err)
+		}
+	}
+}
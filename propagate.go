@@ -0,0 +1,505 @@
+package kenken
+
+import (
+	"container/heap"
+	"math/bits"
+	"slices"
+)
+
+// propagation is the state of constraint propagation during a search.
+type propagation struct {
+	// trail records every candidate removed, so that backtracking can put
+	// them back. Entries with no value mark where fillings were ruled out.
+	trail []Candidate
+	// regions are waiting to have their candidates checked, by position in
+	// p.regions, and queued marks the ones already waiting.
+	regions []int
+	queued  []bool
+	// singles are unset boxes left with one candidate, which has to be
+	// removed from the rest of their row and column.
+	singles []Index
+	// houses marks the rows, then the columns, whose candidates changed since
+	// they were last checked.
+	houses []bool
+	// regionOf is the position in p.regions of the region holding each box,
+	// indexed by y*size+x.
+	regionOf []int
+	live     liveFillings
+}
+
+// startPropagation clears the propagation state, makes every filling live and
+// queues everything to be checked.
+func (p *Puzzle) startPropagation() {
+	n := int(p.size)
+	p.prop = propagation{
+		queued:   make([]bool, len(p.regions)),
+		houses:   make([]bool, 2*n),
+		regionOf: make([]int, n*n),
+		live:     newLiveFillings(p.fillingTables()),
+	}
+	for ri := range p.regions {
+		for idx := range p.regions[ri].indices {
+			p.prop.regionOf[int(idx.Y)*n+int(idx.X)] = ri
+		}
+	}
+	p.queueAll()
+}
+
+// remove rules v out for the box at i, recording it on the trail so undo
+// can restore it. Returns false if v wasn't a candidate.
+func (p *Puzzle) remove(i Index, v uint8) bool {
+	box := p.getBox(i)
+	if !box.HasPossible(v) {
+		return false
+	}
+	box.DeletePossible(v)
+	p.prop.trail = append(p.prop.trail, Candidate{i, v})
+	if box.heapIndex >= 0 {
+		heap.Fix(&p.heap, box.heapIndex)
+		p.countHeapOps(1)
+	}
+	if !box.IsValueSet() {
+		p.queueBox(i)
+		if box.NumPossible() == 1 {
+			p.prop.singles = append(p.prop.singles, i)
+		}
+	}
+	return true
+}
+
+// undo restores every candidate removed since the trail had length mark,
+// and the fillings ruled out since then.
+func (p *Puzzle) undo(mark int) {
+	for n := len(p.prop.trail); n > mark; n-- {
+		c := p.prop.trail[n-1]
+		if c.Value == 0 {
+			continue
+		}
+		box := p.getBox(c.Index)
+		box.AddPossible(c.Value)
+		if box.heapIndex >= 0 {
+			heap.Fix(&p.heap, box.heapIndex)
+			p.countHeapOps(1)
+		}
+	}
+	p.prop.trail = p.prop.trail[:mark]
+	p.prop.live.undo(mark)
+}
+
+// assign sets v in the box, and rules it out for the rest of its row and
+// column.
+func (p *Puzzle) assign(box *Box, v uint8) {
+	box.SetValue(v)
+	p.queueBox(box.idx)
+	p.deletePossibilityFromRow(v, box.idx.Y)
+	p.deletePossibilityFromCol(v, box.idx.X)
+}
+
+// queueBox queues the region, row and column of the box at i to be checked.
+// Outside of a search there is nothing to queue.
+func (p *Puzzle) queueBox(i Index) {
+	if p.prop.regionOf == nil {
+		return
+	}
+	p.queueRegion(p.prop.regionOf[int(i.Y)*int(p.size)+int(i.X)])
+	p.prop.houses[i.Y] = true
+	p.prop.houses[int(p.size)+int(i.X)] = true
+}
+
+func (p *Puzzle) queueRegion(ri int) {
+	if !p.prop.queued[ri] {
+		p.prop.queued[ri] = true
+		p.prop.regions = append(p.prop.regions, ri)
+	}
+}
+
+// queueAll queues every region, row and column, and every unset box with one
+// candidate.
+func (p *Puzzle) queueAll() {
+	for ri := range p.regions {
+		p.queueRegion(ri)
+	}
+	for h := range p.prop.houses {
+		p.prop.houses[h] = true
+	}
+	for y := range p.puzzle {
+		for x, box := range p.puzzle[y] {
+			if !box.IsValueSet() && box.NumPossible() == 1 {
+				p.prop.singles = append(p.prop.singles, Index{uint8(x), uint8(y)})
+			}
+		}
+	}
+}
+
+// nextHouse returns a row or column waiting to be checked, and false if
+// there are none.
+func (p *Puzzle) nextHouse() (House, bool) {
+	for h, queued := range p.prop.houses {
+		if !queued {
+			continue
+		}
+		p.prop.houses[h] = false
+		if h < int(p.size) {
+			return House{Row, uint8(h)}, true
+		}
+		return House{Column, uint8(h - int(p.size))}, true
+	}
+	return House{}, false
+}
+
+// propagate removes candidates until each one left takes part in some way of
+// completing its region, and in some way of giving its row and column
+// different values that add up to their total. Returns false, with
+// the queues cleared, if a box runs out of candidates or a region or house
+// can't be completed.
+func (p *Puzzle) propagate() bool {
+	ok := true
+	for ok {
+		if n := len(p.prop.singles); n > 0 {
+			i := p.prop.singles[n-1]
+			p.prop.singles = p.prop.singles[:n-1]
+			ok = p.propagateSingle(i)
+		} else if len(p.prop.regions) > 0 {
+			ri := p.prop.regions[0]
+			p.prop.regions = p.prop.regions[1:]
+			p.prop.queued[ri] = false
+			ok = p.propagateRegion(ri)
+		} else if h, found := p.nextHouse(); found {
+			ok = p.propagateHouse(h) && p.propagateHouseSum(h)
+		} else {
+			break
+		}
+	}
+	if !ok {
+		p.prop.singles = p.prop.singles[:0]
+		for _, ri := range p.prop.regions {
+			p.prop.queued[ri] = false
+		}
+		p.prop.regions = p.prop.regions[:0]
+		for h := range p.prop.houses {
+			p.prop.houses[h] = false
+		}
+	}
+	return ok
+}
+
+func (p *Puzzle) propagateSingle(i Index) bool {
+	box := p.getBox(i)
+	if box.IsValueSet() {
+		return true
+	}
+	if box.NumPossible() != 1 {
+		return box.NumPossible() != 0
+	}
+	v := box.Possibles().Min()
+	for k := uint8(0); k < p.size; k++ {
+		for _, peer := range []Index{{k, i.Y}, {i.X, k}} {
+			if peer == i || p.getBox(peer).IsValueSet() || !p.remove(peer, v) {
+				continue
+			}
+			p.deleteCandidate(peer, v)
+			if p.getBox(peer).NumPossible() == 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (p *Puzzle) propagateRegion(ri int) bool {
+	r := &p.regions[ri]
+	if t := p.fillings[ri]; t != nil {
+		support := p.liveSupport(ri)
+		for j, idx := range t.cells {
+			if !p.restrict(idx, support[j], r) {
+				return false
+			}
+		}
+		return true
+	}
+	support, ok := p.regionSupport(r)
+	if !ok {
+		return false
+	}
+	for idx := range r.indices {
+		if !p.restrict(idx, support[idx], r) {
+			return false
+		}
+	}
+	return true
+}
+
+// restrict rules out the candidates of the box at i outside set, which
+// are the values the region r allows there. Returns false if none are left.
+func (p *Puzzle) restrict(i Index, set PossibleSet, r *Region) bool {
+	box := p.getBox(i)
+	if box.IsValueSet() {
+		return true
+	}
+	for v := range box.Possibles().Difference(set).All() {
+		p.remove(i, v)
+		p.rejectCandidate(i, v, r)
+	}
+	return box.NumPossible() != 0
+}
+
+// propagateHouse rules out the candidates that can't be part of any way of
+// giving the unsolved boxes of the house different values, using Régin's
+// matching algorithm. This covers hidden singles and every size of naked and
+// hidden subset. Returns false if the house can't be completed.
+func (p *Puzzle) propagateHouse(h House) bool {
+	cells := make([]Index, 0, p.size)
+	domains := make([]PossibleSet, 0, p.size)
+	for k := uint8(0); k < p.size; k++ {
+		box := p.getBox(h.cell(k))
+		if !box.IsValueSet() {
+			cells = append(cells, box.idx)
+			domains = append(domains, box.Possibles())
+		}
+	}
+	narrowed := slices.Clone(domains)
+	if !allDifferent(narrowed) {
+		return false
+	}
+	for j, c := range cells {
+		for v := range domains[j].Difference(narrowed[j]).All() {
+			p.remove(c, v)
+			p.deleteCandidate(c, v)
+		}
+	}
+	return true
+}
+
+// allDifferent narrows each domain to the values it can take in some way of
+// giving every domain a different value, using Régin's matching algorithm.
+// Returns false if there's no such way.
+func allDifferent(domains []PossibleSet) bool {
+	// Match each domain with a value of its own.
+	var owner [MaxSize + 1]int
+	for v := range owner {
+		owner[v] = -1
+	}
+	match := make([]uint8, len(domains))
+	var augment func(i int, seen *PossibleSet) bool
+	augment = func(i int, seen *PossibleSet) bool {
+		for v := range domains[i].Difference(*seen).All() {
+			seen.Add(v)
+			if owner[v] < 0 || augment(owner[v], seen) {
+				owner[v], match[i] = i, v
+				return true
+			}
+		}
+		return false
+	}
+	var all PossibleSet
+	for i := range domains {
+		all = all.Union(domains[i])
+		var seen PossibleSet
+		if !augment(i, &seen) {
+			return false
+		}
+	}
+	// A domain may keep any value that can be freed by passing its match
+	// along to a domain with an unmatched value.
+	free := all
+	for _, v := range match {
+		free.Delete(v)
+	}
+	for grew := true; grew; {
+		grew = false
+		for i := range domains {
+			if !free.Contains(match[i]) && domains[i].Intersect(free) != 0 {
+				free.Add(match[i])
+				grew = true
+			}
+		}
+	}
+	// Otherwise it may keep another domain's value only if they can swap
+	// along a cycle, each reaching the other.
+	reach := make([]uint64, len(domains))
+	for i := range domains {
+		for j := range domains {
+			if j != i && domains[j].Contains(match[i]) {
+				reach[i] |= 1 << j
+			}
+		}
+	}
+	for m := range domains {
+		for i := range domains {
+			if reach[i]&(1<<m) != 0 {
+				reach[i] |= reach[m]
+			}
+		}
+	}
+	for j := range domains {
+		for v := range domains[j].Difference(free).All() {
+			i := owner[v]
+			if i != j && (reach[i]&(1<<j) == 0 || reach[j]&(1<<i) == 0) {
+				domains[j].Delete(v)
+			}
+		}
+	}
+	return true
+}
+
+// propagateHouseSum uses the house's total, 1+2+...+size, to rule out
+// fillings of its regions whose boxes in the house add up to a total that the
+// other regions crossing it can't make up. Returns false if no fillings add
+// up to the house's total.
+func (p *Puzzle) propagateHouseSum(h House) bool {
+	total := int(p.size) * (int(p.size) + 1) / 2
+	key := int(h.Line)
+	if h.Kind == Column {
+		key += int(p.size)
+	}
+	type part struct {
+		region int
+		sums   []uint16
+	}
+	parts := make([]part, 0, p.size)
+	totals := make([]sumSet, 0, p.size)
+	for k := uint8(0); k < p.size; k++ {
+		c := h.cell(k)
+		ri := p.prop.regionOf[int(c.Y)*int(p.size)+int(c.X)]
+		t := p.fillings[ri]
+		if t == nil {
+			return true
+		}
+		if slices.ContainsFunc(parts, func(pt part) bool { return pt.region == ri }) {
+			continue
+		}
+		pt := part{ri, t.sums[key]}
+		s := newSumSet(total)
+		for _, f := range p.prop.live.of(ri) {
+			s.add(int(pt.sums[f]))
+		}
+		parts = append(parts, pt)
+		totals = append(totals, s)
+	}
+	allowed, ok := completeSums(totals, total)
+	if !ok {
+		return false
+	}
+	for k, pt := range parts {
+		if allowed[k] == nil {
+			continue
+		}
+		p.filterLive(pt.region, func(f int32) bool {
+			return allowed[k].has(int(pt.sums[f]))
+		})
+		p.queueRegion(pt.region)
+	}
+	return true
+}
+
+// completeSums returns, for each part, the totals it can give that the other
+// parts can make up to total, each giving one of its own. An entry is nil if
+// every total the part can give is allowed. Returns false if the parts can't
+// make up total at all.
+func completeSums(totals []sumSet, total int) ([]sumSet, bool) {
+	// reached[k] holds the totals the parts before k can give, and needed[k]
+	// the totals that the parts from k on can make up to total.
+	reached := make([]sumSet, len(totals)+1)
+	needed := make([]sumSet, len(totals)+1)
+	reached[0] = newSumSet(total)
+	reached[0].add(0)
+	for k, t := range totals {
+		reached[k+1] = newSumSet(total)
+		t.each(total, func(s int) { reached[k+1].orShifted(reached[k], s) })
+	}
+	if !reached[len(totals)].has(total) {
+		return nil, false
+	}
+	needed[len(totals)] = newSumSet(total)
+	needed[len(totals)].add(total)
+	for k := len(totals) - 1; k >= 0; k-- {
+		needed[k] = newSumSet(total)
+		totals[k].each(total, func(s int) { needed[k].orShiftedDown(needed[k+1], s) })
+	}
+	allowed := make([]sumSet, len(totals))
+	shifted := newSumSet(total)
+	for k, t := range totals {
+		narrowed := newSumSet(total)
+		all := true
+		t.each(total, func(s int) {
+			shifted.clear()
+			shifted.orShifted(reached[k], s)
+			if shifted.intersects(needed[k+1]) {
+				narrowed.add(s)
+			} else {
+				all = false
+			}
+		})
+		if !all {
+			allowed[k] = narrowed
+		}
+	}
+	return allowed, true
+}
+
+// sumSet is a set of totals from 0 up to a maximum.
+type sumSet []uint64
+
+func newSumSet(max int) sumSet {
+	return make(sumSet, max/64+1)
+}
+
+func (s sumSet) add(v int) {
+	s[v/64] |= 1 << (v % 64)
+}
+
+func (s sumSet) has(v int) bool {
+	return v/64 < len(s) && s[v/64]&(1<<(v%64)) != 0
+}
+
+func (s sumSet) clear() {
+	for i := range s {
+		s[i] = 0
+	}
+}
+
+// each calls visit with every total in the set up to max.
+func (s sumSet) each(max int, visit func(v int)) {
+	for i, w := range s {
+		for ; w != 0; w &= w - 1 {
+			v := i*64 + bits.TrailingZeros64(w)
+			if v > max {
+				return
+			}
+			visit(v)
+		}
+	}
+}
+
+// orShifted adds every total of o increased by k, dropping any past the end.
+func (s sumSet) orShifted(o sumSet, k int) {
+	words, bits := k/64, uint(k%64)
+	for i := len(s) - 1; i >= words; i-- {
+		v := o[i-words] << bits
+		if bits > 0 && i-words > 0 {
+			v |= o[i-words-1] >> (64 - bits)
+		}
+		s[i] |= v
+	}
+}
+
+// orShiftedDown adds every total of o decreased by k, dropping any below 0.
+func (s sumSet) orShiftedDown(o sumSet, k int) {
+	words, bits := k/64, uint(k%64)
+	for i := 0; i+words < len(o); i++ {
+		v := o[i+words] >> bits
+		if bits > 0 && i+words+1 < len(o) {
+			v |= o[i+words+1] << (64 - bits)
+		}
+		s[i] |= v
+	}
+}
+
+func (s sumSet) intersects(o sumSet) bool {
+	for i := range s {
+		if s[i]&o[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package kenken
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hardText is a 9x9 puzzle that takes the search hundreds of thousands of
+// nodes without propagation.
+const hardText = `AABCDEEEF
+ABBDDDGHF
+IIJJJKGLM
+NIOOOKKLM
+PPQRRRSSM
+TPQUVVVSS
+TWQUXXYYY
+ZWWaXXbcd
+Zeeabbbcd
+A 23+
+B 9+
+C 1
+D 19+
+E 19+
+F 4+
+G 15+
+H 9
+I 15+
+J 17+
+K 40*
+L 3-
+M 15+
+N 2
+O 216*
+P 120*
+Q 18+
+R 144*
+S 112*
+T 4*
+U 16+
+V 30*
+W 15+
+X 18+
+Y 12+
+Z 63*
+a 1-
+b 22+
+c 9+
+d 45*
+e 6-
+`
+
+// largeCageText is a 9x9 puzzle with cages of up to 8 boxes, which have
+// thousands of fillings each.
+const largeCageText = `AABCCCCDD
+EAAFFGGHD
+EEEEIIGHD
+JJJJIIIID
+KKKJLLLLD
+MMNJOPPPP
+MMNOOOPQQ
+MRROOSTTQ
+UUUUTTTTQ
+A 23+
+B 7
+C 360*
+D 3024*
+E 320*
+F 11+
+G 21+
+H 1-
+I 15120*
+J 35+
+K 12+
+L 20+
+M 32+
+N 11+
+O 504*
+P 840*
+Q 960*
+R 1-
+S 9
+T 25+
+U 280*
+`
+
+func TestPropagateRegion(t *testing.T) {
+	p, _ := NewPuzzleBuilder(3).
+		AddCage(Sum, 4, Index{0, 0}, Index{1, 0}).
+		AddCage(Nothing, 2, Index{2, 0}).
+		AddCage(Sum, 12, Index{0, 1}, Index{1, 1}, Index{2, 1}, Index{0, 2}, Index{1, 2}, Index{2, 2}).
+		Build()
+	if !p.getBox(Index{0, 0}).HasPossible(2) {
+		t.Fatalf("Expected 2 to be a candidate before propagating")
+	}
+	p.startPropagation()
+	if !p.propagate() {
+		t.Fatalf("Propagation failed on a solvable puzzle")
+	}
+	for _, idx := range []Index{{0, 0}, {1, 0}} {
+		if ps := p.getBox(idx).Possibles(); ps.Len() != 2 || ps.Contains(2) {
+			t.Errorf("Expected %v to have candidates 1 and 3, got %v", idx, ps.Values())
+		}
+	}
+	p.undo(0)
+	if !p.getBox(Index{0, 0}).HasPossible(2) || !p.getBox(Index{0, 1}).HasPossible(2) {
+		t.Errorf("Undo didn't restore the candidates")
+	}
+}
+
+func TestPropagateFails(t *testing.T) {
+	p, _ := NewPuzzleBuilder(2).
+		AddCage(Nothing, 1, Index{0, 0}).
+		AddCage(Nothing, 1, Index{1, 0}).
+		AddCage(Sum, 3, Index{0, 1}, Index{1, 1}).
+		Build()
+	p.startPropagation()
+	if p.propagate() {
+		t.Errorf("Propagation succeeded on an unsolveable puzzle")
+	}
+	if len(p.prop.regions) != 0 || len(p.prop.singles) != 0 {
+		t.Errorf("Failed propagation left work queued")
+	}
+}
+
+func TestSolveHard(t *testing.T) {
+	p, err := Parse(strings.NewReader(hardText))
+	if err != nil {
+		t.Fatalf("Failed to parse puzzle: %v", err)
+	}
+	if unique, _ := p.IsUnique(); !unique {
+		t.Fatalf("Expected the puzzle to have one solution")
+	}
+	if err = p.Solve(); err != nil {
+		t.Fatalf("Failed to solve puzzle: %v", err)
+	}
+	if err = p.Validate(); err != nil || !p.isSolved() {
+		t.Errorf("Puzzle wasn't solved: %v", err)
+	}
+}
+
+func BenchmarkSolveHard(b *testing.B) {
+	p, _ := Parse(strings.NewReader(hardText))
+	for i := 0; i < b.N; i++ {
+		p.Clone().Solve()
+	}
+}
+
+func TestSolveLargeCages(t *testing.T) {
+	p, err := Parse(strings.NewReader(largeCageText))
+	if err != nil {
+		t.Fatalf("Failed to parse puzzle: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if _, err = p.SolveContext(ctx, SolveOptions{}); err != nil {
+		t.Fatalf("Failed to solve puzzle: %v", err)
+	}
+	if err = p.Validate(); err != nil || !p.isSolved() {
+		t.Errorf("Puzzle wasn't solved: %v", err)
+	}
+}
+
+func BenchmarkSolveLargeCages(b *testing.B) {
+	p, _ := Parse(strings.NewReader(largeCageText))
+	for i := 0; i < b.N; i++ {
+		p.Clone().Solve()
+	}
+}
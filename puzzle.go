@@ -2,7 +2,10 @@ package kenken
 
 import (
 	"container/heap"
+	"context"
 	"fmt"
+	"iter"
+	"slices"
 	"strings"
 
 	tm "github.com/buger/goterm"
@@ -26,6 +29,12 @@ type Puzzle struct {
 	regions        []Region
 	regionsByIndex map[Index]*Region
 	heap           BoxHeap
+	run            *solveRun
+	prop           propagation
+	combinations   *CombinationTable
+	// fillings caches fillingTables. It depends only on the regions, so
+	// clones share it.
+	fillings []*fillingTable
 }
 
 func NewPuzzle(size uint8) *Puzzle {
@@ -33,17 +42,24 @@ func NewPuzzle(size uint8) *Puzzle {
 	for i := range p {
 		p[i] = make([]Box, size)
 	}
-	return &Puzzle{size, p, nil, make(map[Index]*Region), nil}
+	return &Puzzle{size: size, puzzle: p, regionsByIndex: make(map[Index]*Region)}
 }
 
+// RequestPuzzle asks for the puzzle's cages on the terminal. If the size
+// isn't from 1 to MaxSize, it asks for the size again first.
 func RequestPuzzle(size uint8) *Puzzle {
+	for size == 0 || size > MaxSize {
+		tm.Printf("The size must be from 1 to %v. What is the size of the puzzle?\n", MaxSize)
+		tm.Flush()
+		fmt.Scan(&size)
+	}
 	p := make([][]Box, size)
 	selected := make([][]bool, size)
 	for i := range p {
 		p[i] = make([]Box, size)
 		selected[i] = make([]bool, size)
 	}
-	pzl := &Puzzle{size, p, nil, make(map[Index]*Region), nil}
+	pzl := &Puzzle{size: size, puzzle: p, regionsByIndex: make(map[Index]*Region)}
 	cursor := Index{0, size - 1}
 
 	region := *NewIndexSet()
@@ -86,9 +102,7 @@ func RequestPuzzle(size uint8) *Puzzle {
 		pzl.printWithCursor(cursor, selected, region)
 		tm.Flush()
 	}
-	pzl.prepareRegionsByIndex()
-	pzl.prepareBoxesFromRegions()
-	pzl.buildHeap()
+	pzl.prepare()
 	return pzl
 }
 
@@ -137,6 +151,14 @@ func (p *Puzzle) confirmRegion(region IndexSet) {
 		op = Nothing
 	}
 	p.regions = append(p.regions, Region{result, op, region})
+	p.fillings = nil
+}
+
+// Prepare the puzzle for solving once all regions have been added.
+func (p *Puzzle) prepare() {
+	p.prepareRegionsByIndex()
+	p.prepareBoxesFromRegions()
+	p.buildHeap()
 }
 
 // Fill the p.regionsByIndex container. Must be done once no more modifications will be made to p.regions.
@@ -150,16 +172,12 @@ func (p *Puzzle) prepareRegionsByIndex() {
 
 func (p *Puzzle) prepareBoxesFromRegions() {
 	for _, r := range p.regions {
-		valueMaps := r.GetPossibleMaps(p.Size())
+		valueMaps := p.combos().RegionMaps(&r, p.Size())
 		for _, idx := range r.GetIndices() {
 			box := p.getBox(idx)
 			*box = *NewBox(idx, p.Size())
-			for _, m := range valueMaps {
-				for _, possible := range m.GetSortedList() {
-					if !box.HasPossible(possible) {
-						box.AddPossible(possible)
-					}
-				}
+			for i := range valueMaps {
+				box.possibles = box.possibles.Union(valueMaps[i].Values())
 			}
 		}
 	}
@@ -175,14 +193,104 @@ func (p *Puzzle) buildHeap() {
 	heap.Init(&(*p).heap)
 }
 
+// Solutions returns an iterator over every solution to the puzzle, as grids
+// indexed [y][x]. Each solution is found only when the loop asks for it, and
+// the search runs on a copy so the puzzle itself is left unchanged. An invalid
+// puzzle has no solutions; use Validate to find out why.
+func (p *Puzzle) Solutions() iter.Seq[[][]uint8] {
+	return func(yield func([][]uint8) bool) {
+		if p.Validate() != nil {
+			return
+		}
+		c := p.Clone()
+		c.search(func() bool {
+			return !yield(c.Grid())
+		})
+	}
+}
+
+// DifferingRegions returns the regions holding any cell where the grids a and
+// b differ, such as two solutions to an ambiguous puzzle.
+func (p *Puzzle) DifferingRegions(a, b [][]uint8) []Region {
+	regions := make([]Region, 0)
+	for _, r := range p.regions {
+		for _, idx := range r.indices.SortedSlice() {
+			if a[idx.Y][idx.X] != b[idx.Y][idx.X] {
+				regions = append(regions, r)
+				break
+			}
+		}
+	}
+	return regions
+}
+
+// Clone returns a deep copy of the puzzle, including the values and
+// possibilities of its boxes.
+func (p *Puzzle) Clone() *Puzzle {
+	c := NewPuzzle(p.size)
+	c.combinations = p.combinations
+	c.fillings = p.fillings
+	c.regions = make([]Region, len(p.regions))
+	for i, r := range p.regions {
+		c.regions[i] = *NewRegion(r.op, r.result, r.GetIndices()...)
+	}
+	if len(p.regionsByIndex) > 0 {
+		c.prepareRegionsByIndex()
+	}
+	for y := range p.puzzle {
+		copy(c.puzzle[y], p.puzzle[y])
+	}
+	if p.heap != nil {
+		c.heap = make(BoxHeap, len(p.heap), cap(p.heap))
+		for i, b := range p.heap {
+			c.heap[i] = c.getBox(b.idx)
+		}
+	}
+	return c
+}
+
+// SetCombinationTable makes the puzzle look up cage combinations in t
+// instead of the table shared by every puzzle. Copies of the puzzle share t.
+func (p *Puzzle) SetCombinationTable(t *CombinationTable) {
+	p.combinations = t
+}
+
+func (p *Puzzle) combos() *CombinationTable {
+	if p.combinations == nil {
+		return defaultCombinations
+	}
+	return p.combinations
+}
+
 func (p *Puzzle) getBox(i Index) *Box {
 	return &(*p).puzzle[i.Y][i.X]
 }
 
+// allows reports whether v may go at i: it must be the value already set
+// there, or one of the box's candidates.
+func (p *Puzzle) allows(i Index, v uint8) bool {
+	box := p.getBox(i)
+	if box.IsValueSet() {
+		return box.GetValue() == v
+	}
+	return box.HasPossible(v)
+}
+
 func (p *Puzzle) Size() uint8 {
 	return p.size
 }
 
+// GetValue returns the value at i, or 0 if it has not been solved.
+func (p *Puzzle) GetValue(i Index) uint8 {
+	return p.getBox(i).GetValue()
+}
+
+func (p *Puzzle) GetRegions() []Region {
+	regions := make([]Region, len(p.regions))
+	copy(regions, p.regions)
+	return regions
+}
+
 type UnsolveableError struct {
 	failedPaths uint
 }
@@ -191,103 +299,156 @@ func (e UnsolveableError) Error() string {
 	return fmt.Sprintf("Failed solving puzzle after trying %v paths", e.failedPaths)
 }
 
-func (p *Puzzle) Solve() error {
-	return p.trySolve()
+// search fills the boxes depth first, calling visit each time every box has a
+// value. If visit returns true the search stops and leaves that solution in
+// place; otherwise the board is restored and the search moves on to the next
+// solution. Returns whether visit stopped the search, and the number of paths
+// that failed along the way. A search that has to stop early restores the
+// board and returns false.
+func (p *Puzzle) search(visit func() bool) (bool, uint) {
+	p.startPropagation()
+	stopped, numFailedPaths := p.searchNode(visit)
+	if !stopped {
+		p.undo(0)
+	}
+	return stopped, numFailedPaths
 }
 
-func (p *Puzzle) trySolve() error {
+// searchNode propagates the last value set, then tries each candidate of the
+// box with the fewest, or each filling of a region with few left.
+func (p *Puzzle) searchNode(visit func() bool) (bool, uint) {
+	if !p.enterNode() {
+		return false, 0
+	}
+	if !p.propagate() {
+		return false, 1
+	}
 	if p.heap.Len() == 0 {
-		return nil
+		return visit(), 0
+	}
+	if ri, ok := p.branchRegion(); ok {
+		return p.searchFillings(ri, visit)
 	}
 	numFailedPaths := uint(0)
 	topBox := heap.Pop(&p.heap).(*Box)
-	possibles := topBox.GetPossibles()
-	for _, v := range possibles {
-		if !p.isRegionValidIfSet(*topBox, v) {
-			numFailedPaths++
-			continue
-		}
-		topBox.SetValue(v)
-		modifications := make([]Index, 0)
-		p.deletePossibilityFromRow(v, topBox.idx.Y, &modifications)
-		p.deletePossibilityFromCol(v, topBox.idx.X, &modifications)
-		err := p.trySolve()
-		if err == nil {
-			return nil
-		}
-		switch err.(type) {
-		case UnsolveableError:
-			numFailedPaths += err.(UnsolveableError).failedPaths
-		default:
-			numFailedPaths++
+	p.countHeapOps(1)
+	for v := range topBox.Possibles().All() {
+		p.tryValue(topBox.idx, v)
+		mark := len(p.prop.trail)
+		p.assign(topBox, v)
+		stopped, failedPaths := p.searchNode(visit)
+		numFailedPaths += failedPaths
+		if stopped {
+			return true, numFailedPaths
 		}
-		p.resetPossibilities(v, modifications)
+		p.backtrack(topBox.idx, v)
+		p.undo(mark)
 		topBox.UnsetValue()
+		if p.stopped() {
+			break
+		}
 	}
 	heap.Push(&p.heap, topBox)
-	return UnsolveableError{numFailedPaths}
-}
-
-func (p *Puzzle) isRegionValidIfSet(b Box, v byte) bool {
-	setValues := *NewByteMap()
-	setValues.Add(v)
-	r := *p.regionsByIndex[b.idx]
-	for _, idx := range r.GetIndices() {
-		box := p.puzzle[idx.Y][idx.X]
-		if box.GetValue() != 0 {
-			setValues.Add(box.GetValue())
-		}
-	}
-	possibleMaps := r.GetPossibleMaps(p.Size())
-	for _, posMap := range possibleMaps {
-		isPos := true
-		for setPos, setNum := range setValues.Map() {
-			posNum, present := posMap.Map()[setPos]
-			if !present || posNum < setNum {
-				isPos = false
-			}
+	p.countHeapOps(1)
+	return false, numFailedPaths
+}
+
+// regionBranchFactor is how many fillings a region may have left for each
+// candidate of the box with the fewest, and still be searched through before
+// that box. Setting a whole region at once narrows large cages much faster
+// than setting one box at a time.
+const regionBranchFactor = 4
+
+// branchRegion returns the unsolved region with the fewest fillings left, if
+// it has few enough to search through instead of the box with the fewest
+// candidates.
+func (p *Puzzle) branchRegion() (int, bool) {
+	best, fewest := -1, regionBranchFactor*int(p.heap[0].NumPossible())
+	for ri, t := range p.fillings {
+		n := len(p.prop.live.of(ri))
+		if t == nil || n < 2 || n >= fewest {
+			continue
 		}
-		// If at least one map is still possible, return no error
-		if isPos {
-			return true
+		for _, c := range t.cells {
+			if !p.getBox(c).IsValueSet() {
+				best, fewest = ri, n
+				break
+			}
 		}
 	}
-	return false
+	return best, best >= 0
 }
 
-func (p *Puzzle) deletePossibilityFromRow(v, y byte, m *[]Index) {
-	for x := byte(0); x < p.Size(); x++ {
-		if !p.puzzle[y][x].HasPossible(v) {
-			continue
+// searchFillings sets the unsolved boxes of the region to each of its
+// fillings left in turn.
+func (p *Puzzle) searchFillings(ri int, visit func() bool) (bool, uint) {
+	t := p.fillings[ri]
+	var boxes []*Box
+	var positions []int
+	for j, c := range t.cells {
+		if box := p.getBox(c); !box.IsValueSet() {
+			heap.Remove(&p.heap, box.heapIndex)
+			boxes = append(boxes, box)
+			positions = append(positions, j)
 		}
-		idx := Index{x, y}
-		*m = append(*m, idx)
-		p.puzzle[y][x].DeletePossible(v)
-		if p.puzzle[y][x].heapIndex >= 0 {
-			heap.Fix(&p.heap, p.puzzle[y][x].heapIndex)
+	}
+	p.countHeapOps(uint(len(boxes)))
+	numFailedPaths := uint(0)
+	for _, f := range slices.Clone(p.prop.live.of(ri)) {
+		values := t.filling(f)
+		mark := len(p.prop.trail)
+		for k, box := range boxes {
+			p.tryValue(box.idx, values[positions[k]])
+			p.assign(box, values[positions[k]])
+		}
+		stopped, failedPaths := p.searchNode(visit)
+		numFailedPaths += failedPaths
+		if stopped {
+			return true, numFailedPaths
+		}
+		for k := len(boxes) - 1; k >= 0; k-- {
+			p.backtrack(boxes[k].idx, values[positions[k]])
+		}
+		p.undo(mark)
+		for _, box := range boxes {
+			box.UnsetValue()
+		}
+		if p.stopped() {
+			break
 		}
 	}
+	for _, box := range boxes {
+		heap.Push(&p.heap, box)
+	}
+	p.countHeapOps(uint(len(boxes)))
+	return false, numFailedPaths
 }
 
-func (p *Puzzle) deletePossibilityFromCol(v, x byte, m *[]Index) {
-	for y := byte(0); y < p.Size(); y++ {
-		if !p.puzzle[y][x].HasPossible(v) {
-			continue
-		}
-		idx := Index{x, y}
-		*m = append(*m, idx)
-		p.puzzle[y][x].DeletePossible(v)
-		if p.puzzle[y][x].heapIndex >= 0 {
-			heap.Fix(&p.heap, p.puzzle[y][x].heapIndex)
+// CountSolutions returns the number of solutions the puzzle has, stopping
+// once limit have been found. A limit of 0 counts every solution. The puzzle
+// itself is left unchanged.
+func (p *Puzzle) CountSolutions(limit int) (int, error) {
+	return Backtrack{}.Count(context.Background(), p, limit)
+}
+
+// IsUnique reports whether the puzzle has exactly one solution.
+func (p *Puzzle) IsUnique() (bool, error) {
+	count, err := p.CountSolutions(2)
+	return count == 1, err
+}
+
+func (p *Puzzle) deletePossibilityFromRow(v, y byte) {
+	for x := byte(0); x < p.Size(); x++ {
+		if p.remove(Index{x, y}, v) {
+			p.deleteCandidate(Index{x, y}, v)
 		}
 	}
 }
 
-func (p *Puzzle) resetPossibilities(v byte, modifications []Index) {
-	for _, idx := range modifications {
-		p.puzzle[idx.Y][idx.X].AddPossible(v)
-		if p.puzzle[idx.Y][idx.X].heapIndex >= 0 {
-			heap.Fix(&p.heap, p.puzzle[idx.Y][idx.X].heapIndex)
+func (p *Puzzle) deletePossibilityFromCol(v, x byte) {
+	for y := byte(0); y < p.Size(); y++ {
+		if p.remove(Index{x, y}, v) {
+			p.deleteCandidate(Index{x, y}, v)
 		}
 	}
 }
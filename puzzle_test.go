@@ -123,7 +123,7 @@ func checkBoxPossibles(t *testing.T, b *Box, possibles []byte) {
 }
 
 func TestPuzzleMoveCursorDisallowed(t *testing.T) {
-	p := Puzzle{2, nil, nil, nil, nil}
+	p := Puzzle{size: 2}
 	tl := Index{0, 1}
 	tr := Index{1, 1}
 	bl := Index{0, 0}
@@ -281,3 +281,178 @@ func exampleSolution2() [][]uint8 {
 		[]uint8{5, 1, 2, 3, 4},
 	}
 }
+
+func TestCountSolutions(t *testing.T) {
+	p, _ := examplePuzzleBuilder().Build()
+	count, err := p.CountSolutions(0)
+	if err != nil || count != 1 {
+		t.Errorf("Counted %v solutions with error %v, expected 1", count, err)
+	}
+	if p.heap.Len() != 25 || p.GetValue(Index{0, 0}) != 0 {
+		t.Errorf("Counting solutions modified the puzzle:\n%v", p)
+	}
+
+	p, _ = NewPuzzleBuilder(2).
+		AddCage(Nothing, 1, Index{0, 0}).
+		AddCage(Nothing, 1, Index{0, 1}).
+		AddCage(Mul, 2, Index{1, 0}, Index{1, 1}).
+		Build()
+	if count, _ = p.CountSolutions(0); count != 0 {
+		t.Errorf("Counted %v solutions for an unsolveable puzzle", count)
+	}
+}
+
+func TestCountSolutionsAmbiguous(t *testing.T) {
+	p, _ := NewPuzzleBuilder(3).
+		AddCage(Sum, 6, Index{0, 0}, Index{1, 0}, Index{2, 0}).
+		AddCage(Sum, 6, Index{0, 1}, Index{1, 1}, Index{2, 1}).
+		AddCage(Sum, 6, Index{0, 2}, Index{1, 2}, Index{2, 2}).
+		Build()
+	count, _ := p.CountSolutions(0)
+	if count != 12 {
+		t.Errorf("Counted %v solutions, expected %v", count, 12)
+	}
+	if count, _ = p.CountSolutions(5); count != 5 {
+		t.Errorf("Counted %v solutions with a limit of 5", count)
+	}
+	if unique, _ := p.IsUnique(); unique {
+		t.Errorf("IsUnique returned true for an ambiguous puzzle")
+	}
+	p, _ = examplePuzzleBuilder().Build()
+	if unique, err := p.IsUnique(); !unique || err != nil {
+		t.Errorf("IsUnique returned false for a unique puzzle, with error: %v", err)
+	}
+}
+
+func TestClone(t *testing.T) {
+	p, _ := examplePuzzleBuilder().Build()
+	p.place(Index{2, 3}, 3)
+	c := p.Clone()
+	if err := c.Solve(); err != nil {
+		t.Fatalf("Solve failed on the clone with error: %v", err)
+	}
+	if p.GetValue(Index{0, 0}) != 0 || p.heap.Len() != 24 {
+		t.Errorf("Solving the clone modified the original:\n%v", p)
+	}
+	if !p.puzzle[0][0].HasPossible(3) || p.puzzle[3][0].HasPossible(3) {
+		t.Errorf("Original possibilities changed after cloning")
+	}
+	if err := p.Solve(); err != nil {
+		t.Fatalf("Solve failed on the original with error: %v", err)
+	}
+}
+
+func TestSolutions(t *testing.T) {
+	p, _ := NewPuzzleBuilder(3).
+		AddCage(Sum, 6, Index{0, 0}, Index{1, 0}, Index{2, 0}).
+		AddCage(Sum, 6, Index{0, 1}, Index{1, 1}, Index{2, 1}).
+		AddCage(Sum, 6, Index{0, 2}, Index{1, 2}, Index{2, 2}).
+		Build()
+	seen := make(map[string]bool)
+	for s := range p.Solutions() {
+		key := fmt.Sprint(s)
+		if seen[key] {
+			t.Errorf("Solution was returned twice: %v", s)
+		}
+		seen[key] = true
+		if !isLatinSquare(s) {
+			t.Errorf("Solution is not a latin square: %v", s)
+		}
+	}
+	if len(seen) != 12 {
+		t.Errorf("Found %v solutions, expected %v", len(seen), 12)
+	}
+	if p.heap.Len() != 9 || p.GetValue(Index{0, 0}) != 0 {
+		t.Errorf("Iterating over solutions modified the puzzle:\n%v", p)
+	}
+
+	n := 0
+	for range p.Solutions() {
+		n++
+		if n == 2 {
+			break
+		}
+	}
+	if n != 2 {
+		t.Errorf("Stopping early visited %v solutions", n)
+	}
+}
+
+func TestSolutionsUnique(t *testing.T) {
+	p, _ := examplePuzzleBuilder().Build()
+	n := 0
+	for s := range p.Solutions() {
+		n++
+		exp := exampleSolution()
+		for y := range s {
+			for x := range s[y] {
+				if s[y][x] != exp[y][x] {
+					t.Fatalf("Wrong solution: %v", s)
+				}
+			}
+		}
+	}
+	if n != 1 {
+		t.Errorf("Found %v solutions, expected 1", n)
+	}
+}
+
+func TestDifferingRegions(t *testing.T) {
+	p, _ := NewPuzzleBuilder(2).
+		AddCage(Sum, 3, Index{0, 0}, Index{1, 0}).
+		AddCage(Sum, 3, Index{0, 1}, Index{1, 1}).
+		Build()
+	solutions := make([][][]uint8, 0, 2)
+	for s := range p.Solutions() {
+		solutions = append(solutions, s)
+	}
+	if len(solutions) != 2 {
+		t.Fatalf("Found %v solutions, expected 2", len(solutions))
+	}
+	if regions := p.DifferingRegions(solutions[0], solutions[1]); len(regions) != 2 {
+		t.Errorf("Found %v differing regions, expected 2", len(regions))
+	}
+	if regions := p.DifferingRegions(solutions[0], solutions[0]); len(regions) != 0 {
+		t.Errorf("Found %v differing regions between identical grids", len(regions))
+	}
+}
+
+func isLatinSquare(s [][]uint8) bool {
+	n := len(s)
+	for i := 0; i < n; i++ {
+		row, col := make(map[uint8]bool), make(map[uint8]bool)
+		for j := 0; j < n; j++ {
+			if s[i][j] < 1 || int(s[i][j]) > n || row[s[i][j]] || col[s[j][i]] {
+				return false
+			}
+			row[s[i][j]], col[s[j][i]] = true, true
+		}
+	}
+	return true
+}
+
+func BenchmarkSolveExample(b *testing.B) {
+	p, _ := examplePuzzleBuilder().Build()
+	for i := 0; i < b.N; i++ {
+		p.Clone().Solve()
+	}
+}
+
+func BenchmarkSolveExample2(b *testing.B) {
+	p, _ := examplePuzzle2Builder().Build()
+	for i := 0; i < b.N; i++ {
+		p.Clone().Solve()
+	}
+}
+
+func BenchmarkCountSolutions(b *testing.B) {
+	p, _ := NewPuzzleBuilder(4).
+		AddCage(Sum, 10, Index{0, 0}, Index{1, 0}, Index{2, 0}, Index{3, 0}).
+		AddCage(Sum, 10, Index{0, 1}, Index{1, 1}, Index{2, 1}, Index{3, 1}).
+		AddCage(Sum, 10, Index{0, 2}, Index{1, 2}, Index{2, 2}, Index{3, 2}).
+		AddCage(Sum, 10, Index{0, 3}, Index{1, 3}, Index{2, 3}, Index{3, 3}).
+		Build()
+	for i := 0; i < b.N; i++ {
+		p.CountSolutions(0)
+	}
+}
@@ -31,12 +31,49 @@ func (o Operation) String() string {
 	}
 }
 
+// Symbol returns the character used to write the operation in a puzzle, with
+// "=" standing for Nothing.
+func (o Operation) Symbol() string {
+	switch o {
+	case Sum:
+		return "+"
+	case Sub:
+		return "-"
+	case Mul:
+		return "*"
+	case Div:
+		return "/"
+	case Nothing:
+		return "="
+	default:
+		return "?"
+	}
+}
+
+// OperationFromSymbol is the inverse of Operation.Symbol.
+func OperationFromSymbol(s string) (Operation, bool) {
+	for _, o := range []Operation{Sum, Sub, Mul, Div, Nothing} {
+		if o.Symbol() == s {
+			return o, true
+		}
+	}
+	return 0, false
+}
+
 type Region struct {
 	result  uint
 	op      Operation
 	indices IndexSet
 }
 
+func NewRegion(op Operation, result uint, indices ...Index) *Region {
+	is := *NewIndexSet()
+	for _, idx := range indices {
+		is.Add(idx)
+	}
+	return &Region{result, op, is}
+}
+
 func (r Region) GetResult() uint {
 	return r.result
 }
@@ -59,76 +96,10 @@ func (r Region) String() string {
 	return fmt.Sprintf("Result: %v, Operation: %v, Indices: %v", r.result, r.op, r.indices)
 }
 
+// GetPossibleMaps returns every multiset of values that satisfies the region,
+// using the shared CombinationTable. The maps must not be modified.
 func (r *Region) GetPossibleMaps(size uint8) ByteMapList {
-	switch (*r).op {
-	case Sum:
-		return r.getSumMaps(size)
-	case Sub:
-		return r.getSubMaps(size)
-	case Mul:
-		return r.getMulMaps(size)
-	case Div:
-		return r.getDivMaps(size)
-	case Nothing:
-		return r.getNothingMaps()
-	}
-	return nil
-}
-
-func (r *Region) getNothingMaps() ByteMapList {
-	maps := make(ByteMapList, 1)
-	maps[0] = *NewByteMap()
-	maps[0].Add(byte((*r).result))
-	return maps
-}
-
-func (r *Region) getSumMaps(size uint8) ByteMapList {
-	numArgs := r.indices.Len()
-	result := r.result
-	maps := getSumMapsForResult(size, uint(numArgs), result)
-	return maps
-}
-
-func (r *Region) getSubMaps(size uint8) ByteMapList {
-	numArgs := uint(r.indices.Len())
-	result := uint(r.result)
-	key := opMapKey{Sub, size, numArgs, result}
-	maps, present := opMaps[key]
-	if present {
-		// return maps
-	}
-	maps = make(ByteMapList, 0)
-	for i := uint8(result + 1); i <= size; i++ {
-		innerMaps := getSumMapsForResult(size, uint(numArgs-1), uint(i)-result)
-		maps.appendValueAndAdd(&innerMaps, i)
-	}
-	return maps
-}
-
-func (r *Region) getMulMaps(size uint8) ByteMapList {
-	numArgs := r.indices.Len()
-	result := r.result
-	maps := getMulMapsForResult(size, uint(numArgs), result)
-	return maps
-}
-
-func (r *Region) getDivMaps(size uint8) ByteMapList {
-	numArgs := uint(r.indices.Len())
-	result := uint(r.result)
-	key := opMapKey{Div, size, numArgs, result}
-	maps, present := opMaps[key]
-	if present {
-		// return maps
-	}
-	maps = make(ByteMapList, 0)
-	for i := uint8(1); i <= size; i++ {
-		if i%uint8(result) != 0 {
-			continue
-		}
-		innerMaps := getMulMapsForResult(size, numArgs-1, uint(i)/result)
-		maps.appendValueAndAdd(&innerMaps, i)
-	}
-	return maps
+	return defaultCombinations.RegionMaps(r, size)
 }
 
 type opMapKey struct {
@@ -160,57 +131,59 @@ func (l *ByteMapList) appendValueAndAdd(il *ByteMapList, v byte) {
 	}
 }
 
-var opMaps = make(map[opMapKey]ByteMapList)
-
 func getSumMapsForResult(size uint8, numArgs uint, result uint) ByteMapList {
-	key := opMapKey{Sum, size, numArgs, result}
-	maps, present := opMaps[key]
-	if present {
-		// return maps
-	}
-	maps = make(ByteMapList, 0)
-	for i := uint8(1); i <= size && uint(i) <= result; i++ {
-		if numArgs == 1 {
-			if result != uint(i) {
-				continue
-			}
-			m := *NewByteMap()
-			m.Add(i)
-			maps = append(maps, m)
-		} else {
-			if result <= uint(i) {
-				break
-			}
-			innerMaps := getSumMapsForResult(size, numArgs-1, result-uint(i))
-			maps.appendValueAndAdd(&innerMaps, i)
-		}
-	}
-	opMaps[key] = maps
-	return maps
+	return defaultCombinations.Maps(Sum, size, numArgs, result)
 }
 
 func getMulMapsForResult(size uint8, numArgs uint, result uint) ByteMapList {
-	key := opMapKey{Mul, size, numArgs, result}
-	maps, present := opMaps[key]
-	if present {
-		// return maps
-	}
-	maps = make(ByteMapList, 0)
-	if numArgs == 1 {
-		if result <= uint(size) {
-			m := *NewByteMap()
-			m.Add(byte(result))
-			maps = append(maps, m)
-		}
-	} else {
-		for i := uint8(1); i <= size; i++ {
-			if result%uint(i) != 0 {
-				continue
+	return defaultCombinations.Maps(Mul, size, numArgs, result)
+}
+
+// forEachAssignment calls visit with every way of filling the region's cells
+// from one of its possible maps in t without repeating a value in a row or
+// column.
+// Cells are passed in sorted order and values[i] belongs to cells[i]; both
+// slices are reused between calls. If allowed is not nil, only values it
+// accepts are tried. Returns true if visit stopped the iteration by
+// returning true.
+func (r *Region) forEachAssignment(t *CombinationTable, size uint8, allowed func(Index, uint8) bool, visit func(cells []Index, values []uint8) bool) bool {
+	cells := r.indices.SortedSlice()
+	values := make([]uint8, len(cells))
+	for _, m := range t.RegionMaps(r, size) {
+		counts := m.Copy()
+		distinct := counts.Values().Intersect(FullSet(size)).Values()
+		var assign func(k int) bool
+		assign = func(k int) bool {
+			if k == len(cells) {
+				return visit(cells, values)
+			}
+			for _, v := range distinct {
+				if counts.counts[v] == 0 || (allowed != nil && !allowed(cells[k], v)) {
+					continue
+				}
+				clash := false
+				for j := 0; j < k; j++ {
+					if values[j] == v && (cells[j].X == cells[k].X || cells[j].Y == cells[k].Y) {
+						clash = true
+						break
+					}
+				}
+				if clash {
+					continue
+				}
+				values[k] = v
+				counts.counts[v]--
+				stop := assign(k + 1)
+				counts.counts[v]++
+				if stop {
+					return true
+				}
 			}
-			innerMaps := getMulMapsForResult(size, numArgs-1, result/uint(i))
-			maps.appendValueAndAdd(&innerMaps, i)
+			return false
+		}
+		if assign(0) {
+			return true
 		}
 	}
-	opMaps[key] = maps
-	return maps
+	return false
 }
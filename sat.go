@@ -0,0 +1,599 @@
+package kenken
+
+import (
+	"container/heap"
+	"context"
+	"sort"
+	"time"
+)
+
+// satSolver is a CDCL SAT solver in the style of MiniSat: two watched
+// literals per clause, first UIP clause learning, VSIDS branching with saved
+// phases, and restarts on the Luby sequence. Between restarts, once there are
+// more learnt clauses than maxLearnts, half of them are deleted, those
+// spanning the most decision levels first, as in Glucose. Clauses spanning
+// two levels or fewer are kept for good.
+//
+// Variables are numbered from 0, and literal 2v is v while 2v+1 is its
+// negation.
+type satSolver struct {
+	clauses [][]int
+	// lbd is the number of decision levels each learnt clause spanned when it
+	// was learnt, or 0 for the clauses that were added.
+	lbd []int
+	// next is where, past its first two literals, each clause's search for a
+	// literal to watch starts, which is where the last search stopped.
+	next       []int
+	learnts    int
+	maxLearnts int
+	// watches holds, for each literal, the clauses whose first two literals
+	// include it.
+	watches [][]satWatch
+	// assigns is 1 for true, -1 for false and 0 for unassigned, by variable.
+	assigns []int8
+	level   []int
+	// reason is the clause that implied each variable, or -1.
+	reason []int
+	phase  []bool
+	seen   []bool
+	// levelSeen marks the levels counted for the LBD of the clause being
+	// learnt with stamp.
+	levelSeen []int
+	stamp     int
+	// trail lists the true literals in the order they were assigned, and
+	// trailLim is where each decision level starts in it.
+	trail    []int
+	trailLim []int
+	qhead    int
+	order    satOrder
+	varInc   float64
+	// decisions is how many variables, from 0, may be branched on. The rest
+	// must follow from them.
+	decisions int
+	// conflicts counts the conflicts met, for UnsolveableError.
+	conflicts uint
+	// ok is false once the clauses are known to be unsatisfiable.
+	ok bool
+}
+
+// satWatch is a clause watching a literal, with another of its literals.
+// While that blocker is true the clause is satisfied, and propagate can skip
+// it without looking at the clause itself.
+type satWatch struct {
+	clause, blocker int
+}
+
+// satOrder is a heap of the unassigned variables, most active first.
+type satOrder struct {
+	vars     []int
+	index    []int
+	activity []float64
+}
+
+func (h satOrder) Len() int { return len(h.vars) }
+
+func (h satOrder) Less(i, j int) bool {
+	return h.activity[h.vars[i]] > h.activity[h.vars[j]]
+}
+
+func (h *satOrder) Swap(i, j int) {
+	h.vars[i], h.vars[j] = h.vars[j], h.vars[i]
+	h.index[h.vars[i]] = i
+	h.index[h.vars[j]] = j
+}
+
+func (h *satOrder) Push(v interface{}) {
+	h.index[v.(int)] = len(h.vars)
+	h.vars = append(h.vars, v.(int))
+}
+
+func (h *satOrder) Pop() interface{} {
+	v := h.vars[len(h.vars)-1]
+	h.index[v] = -1
+	h.vars = h.vars[:len(h.vars)-1]
+	return v
+}
+
+func newSATSolver(numVars int) *satSolver {
+	s := &satSolver{
+		watches:   make([][]satWatch, 2*numVars),
+		assigns:   make([]int8, numVars),
+		level:     make([]int, numVars),
+		reason:    make([]int, numVars),
+		phase:     make([]bool, numVars),
+		seen:      make([]bool, numVars),
+		levelSeen: make([]int, numVars+1),
+		order:     satOrder{index: make([]int, numVars), activity: make([]float64, numVars)},
+		varInc:    1,
+		decisions: numVars,
+		ok:        true,
+	}
+	for v := 0; v < numVars; v++ {
+		s.reason[v] = -1
+		heap.Push(&s.order, v)
+	}
+	return s
+}
+
+// limitDecisions stops the solver branching on variables from n on.
+func (s *satSolver) limitDecisions(n int) {
+	s.decisions = n
+	vars := s.order.vars[:0]
+	for _, v := range s.order.vars {
+		if v < n {
+			vars = append(vars, v)
+		} else {
+			s.order.index[v] = -1
+		}
+	}
+	s.order.vars = vars
+	for i, v := range vars {
+		s.order.index[v] = i
+	}
+	heap.Init(&s.order)
+}
+
+// satLit converts a DIMACS literal to the solver's numbering.
+func satLit(lit int) int {
+	if lit < 0 {
+		return 2*(-lit-1) + 1
+	}
+	return 2 * (lit - 1)
+}
+
+// value returns 1 if the literal is true, -1 if false and 0 if unassigned.
+func (s *satSolver) value(lit int) int8 {
+	if lit&1 == 1 {
+		return -s.assigns[lit>>1]
+	}
+	return s.assigns[lit>>1]
+}
+
+func (s *satSolver) decisionLevel() int {
+	return len(s.trailLim)
+}
+
+func (s *satSolver) enqueue(lit, reason int) {
+	v := lit >> 1
+	s.assigns[v] = 1 - 2*int8(lit&1)
+	s.level[v] = s.decisionLevel()
+	s.reason[v] = reason
+	s.trail = append(s.trail, lit)
+}
+
+// addClause adds a clause of DIMACS literals. It must be called before
+// solving, or after backtracking to level 0.
+func (s *satSolver) addClause(lits []int) {
+	if !s.ok {
+		return
+	}
+	clause := make([]int, 0, len(lits))
+	for _, l := range lits {
+		lit := satLit(l)
+		switch s.value(lit) {
+		case 1:
+			return
+		case -1:
+			continue
+		}
+		duplicate := false
+		for _, c := range clause {
+			if c == lit^1 {
+				return
+			}
+			duplicate = duplicate || c == lit
+		}
+		if !duplicate {
+			clause = append(clause, lit)
+		}
+	}
+	switch len(clause) {
+	case 0:
+		s.ok = false
+	case 1:
+		s.enqueue(clause[0], -1)
+		s.ok = s.propagate() < 0
+	default:
+		s.attach(clause, 0)
+	}
+}
+
+// attach adds a clause and watches its first two literals. lbd is 0 unless
+// the clause was learnt.
+func (s *satSolver) attach(clause []int, lbd int) int {
+	ci := len(s.clauses)
+	s.clauses = append(s.clauses, clause)
+	s.lbd = append(s.lbd, lbd)
+	s.next = append(s.next, 2)
+	if lbd > 0 {
+		s.learnts++
+	}
+	s.watches[clause[0]] = append(s.watches[clause[0]], satWatch{ci, clause[1]})
+	s.watches[clause[1]] = append(s.watches[clause[1]], satWatch{ci, clause[0]})
+	return ci
+}
+
+// propagate assigns the literals implied by unit clauses. It returns the
+// index of a clause with every literal false, or -1 if there is none.
+func (s *satSolver) propagate() int {
+	for s.qhead < len(s.trail) {
+		falseLit := s.trail[s.qhead] ^ 1
+		s.qhead++
+		ws := s.watches[falseLit]
+		s.watches[falseLit] = nil
+		kept := ws[:0]
+		for i := 0; i < len(ws); i++ {
+			w := ws[i]
+			if s.value(w.blocker) == 1 {
+				kept = append(kept, w)
+				continue
+			}
+			ci := w.clause
+			c := s.clauses[ci]
+			if c[0] == falseLit {
+				c[0], c[1] = c[1], c[0]
+			}
+			w.blocker = c[0]
+			if s.value(c[0]) == 1 {
+				kept = append(kept, w)
+				continue
+			}
+			moved := false
+			for j, k := 2, s.next[ci]; j < len(c); j, k = j+1, k+1 {
+				if k == len(c) {
+					k = 2
+				}
+				if s.value(c[k]) != -1 {
+					c[1], c[k] = c[k], c[1]
+					s.watches[c[1]] = append(s.watches[c[1]], w)
+					s.next[ci] = k
+					moved = true
+					break
+				}
+			}
+			if moved {
+				continue
+			}
+			kept = append(kept, w)
+			if s.value(c[0]) == -1 {
+				kept = append(kept, ws[i+1:]...)
+				s.watches[falseLit] = kept
+				s.qhead = len(s.trail)
+				return ci
+			}
+			s.enqueue(c[0], ci)
+		}
+		s.watches[falseLit] = kept
+	}
+	return -1
+}
+
+// analyze finds the first unique implication point of the conflict. It
+// returns the learnt clause, with the literal to assert first and one from
+// the level to backtrack to second, and that level.
+func (s *satSolver) analyze(confl int) ([]int, int) {
+	learnt := []int{-1}
+	pathCount, lit, i := 0, -1, len(s.trail)-1
+	for {
+		c := s.clauses[confl]
+		start := 0
+		if lit >= 0 {
+			start = 1
+		}
+		for _, q := range c[start:] {
+			v := q >> 1
+			if s.seen[v] || s.level[v] == 0 {
+				continue
+			}
+			s.bump(v)
+			s.seen[v] = true
+			if s.level[v] >= s.decisionLevel() {
+				pathCount++
+			} else {
+				learnt = append(learnt, q)
+			}
+		}
+		for !s.seen[s.trail[i]>>1] {
+			i--
+		}
+		lit = s.trail[i]
+		i--
+		confl = s.reason[lit>>1]
+		s.seen[lit>>1] = false
+		pathCount--
+		if pathCount == 0 {
+			break
+		}
+	}
+	learnt[0] = lit ^ 1
+
+	level := 0
+	for k := 1; k < len(learnt); k++ {
+		s.seen[learnt[k]>>1] = false
+		if l := s.level[learnt[k]>>1]; l > level {
+			level = l
+			learnt[1], learnt[k] = learnt[k], learnt[1]
+		}
+	}
+	return learnt, level
+}
+
+// levels returns the number of decision levels among the literals.
+func (s *satSolver) levels(lits []int) int {
+	s.stamp++
+	n := 0
+	for _, lit := range lits {
+		if l := s.level[lit>>1]; s.levelSeen[l] != s.stamp {
+			s.levelSeen[l] = s.stamp
+			n++
+		}
+	}
+	return n
+}
+
+// reduce deletes half of the learnt clauses, keeping those that span the
+// fewest decision levels. It must be called at level 0, where no reasons are
+// needed by analyze.
+func (s *satSolver) reduce() {
+	candidates := make([]int, 0, s.learnts)
+	for ci, lbd := range s.lbd {
+		if lbd > 2 {
+			candidates = append(candidates, ci)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return s.lbd[candidates[i]] > s.lbd[candidates[j]]
+	})
+	deleted := make([]bool, len(s.clauses))
+	for _, ci := range candidates[:min(len(candidates), s.learnts/2)] {
+		deleted[ci] = true
+		s.learnts--
+	}
+	renumber := make([]int, len(s.clauses))
+	kept := 0
+	for ci := range s.clauses {
+		renumber[ci] = -1
+		if !deleted[ci] {
+			renumber[ci] = kept
+			s.clauses[kept], s.lbd[kept], s.next[kept] = s.clauses[ci], s.lbd[ci], s.next[ci]
+			kept++
+		}
+	}
+	s.clauses, s.lbd, s.next = s.clauses[:kept], s.lbd[:kept], s.next[:kept]
+	for lit, ws := range s.watches {
+		k := 0
+		for _, w := range ws {
+			if renumber[w.clause] >= 0 {
+				ws[k] = satWatch{renumber[w.clause], w.blocker}
+				k++
+			}
+		}
+		s.watches[lit] = ws[:k]
+	}
+	for _, lit := range s.trail {
+		if ci := s.reason[lit>>1]; ci >= 0 {
+			s.reason[lit>>1] = renumber[ci]
+		}
+	}
+	s.maxLearnts += s.maxLearnts / 10
+}
+
+// bump raises the activity of v, so it's chosen sooner.
+func (s *satSolver) bump(v int) {
+	s.order.activity[v] += s.varInc
+	if s.order.activity[v] > 1e100 {
+		for u := range s.order.activity {
+			s.order.activity[u] *= 1e-100
+		}
+		s.varInc *= 1e-100
+	}
+	if s.order.index[v] >= 0 {
+		heap.Fix(&s.order, s.order.index[v])
+	}
+}
+
+// cancelUntil undoes every assignment made above the given level.
+func (s *satSolver) cancelUntil(level int) {
+	if s.decisionLevel() <= level {
+		return
+	}
+	for k := len(s.trail) - 1; k >= s.trailLim[level]; k-- {
+		v := s.trail[k] >> 1
+		s.phase[v] = s.assigns[v] > 0
+		s.assigns[v] = 0
+		s.reason[v] = -1
+		if v < s.decisions && s.order.index[v] < 0 {
+			heap.Push(&s.order, v)
+		}
+	}
+	s.trail = s.trail[:s.trailLim[level]]
+	s.trailLim = s.trailLim[:level]
+	s.qhead = len(s.trail)
+}
+
+// search runs until it finds a model, proves there is none, or meets
+// maxConflicts conflicts. It returns 1, -1 or 0 respectively.
+func (s *satSolver) search(ctx context.Context, maxConflicts int) (int, error) {
+	conflicts := 0
+	for {
+		if confl := s.propagate(); confl >= 0 {
+			s.conflicts++
+			conflicts++
+			if s.decisionLevel() == 0 {
+				return -1, nil
+			}
+			if err := ctx.Err(); err != nil {
+				return 0, err
+			}
+			learnt, level := s.analyze(confl)
+			lbd := s.levels(learnt)
+			s.cancelUntil(level)
+			if len(learnt) == 1 {
+				s.enqueue(learnt[0], -1)
+			} else {
+				s.enqueue(learnt[0], s.attach(learnt, lbd))
+			}
+			s.varInc /= 0.95
+			continue
+		}
+		if conflicts >= maxConflicts {
+			s.cancelUntil(0)
+			return 0, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		v := -1
+		for s.order.Len() > 0 {
+			if u := heap.Pop(&s.order).(int); s.assigns[u] == 0 {
+				v = u
+				break
+			}
+		}
+		if v < 0 {
+			return 1, nil
+		}
+		s.trailLim = append(s.trailLim, len(s.trail))
+		lit := 2*v + 1
+		if s.phase[v] {
+			lit = 2 * v
+		}
+		s.enqueue(lit, -1)
+	}
+}
+
+// luby returns the i-th term, from 0, of the Luby sequence 1 1 2 1 1 2 4 ...
+func luby(i int) int {
+	size, seq := 1, 0
+	for size < i+1 {
+		seq++
+		size = 2*size + 1
+	}
+	for size-1 != i {
+		size = (size - 1) / 2
+		seq--
+		i %= size
+	}
+	return 1 << seq
+}
+
+// solve reports whether the clauses are satisfiable, leaving the model
+// assigned if they are.
+func (s *satSolver) solve(ctx context.Context) (bool, error) {
+	if !s.ok {
+		return false, nil
+	}
+	if s.maxLearnts == 0 {
+		s.maxLearnts = max(len(s.clauses)/3, 1000)
+	}
+	for restart := 0; ; restart++ {
+		if s.learnts > s.maxLearnts {
+			s.reduce()
+		}
+		result, err := s.search(ctx, 100*luby(restart))
+		if err != nil {
+			s.cancelUntil(0)
+			return false, err
+		}
+		if result != 0 {
+			s.ok = result > 0
+			return s.ok, nil
+		}
+	}
+}
+
+// satFor encodes the puzzle and loads it into a new solver.
+func (p *Puzzle) satFor() *satSolver {
+	f := p.cnf()
+	s := newSATSolver(f.numVars)
+	for _, c := range f.clauses {
+		s.addClause(c)
+	}
+	// The values of the cells decide how each cage is filled.
+	n := int(p.size)
+	s.limitDecisions(n * n * n)
+	return s
+}
+
+// satGrid reads the values of a model found for the puzzle.
+func (p *Puzzle) satGrid(s *satSolver) [][]uint8 {
+	grid := make([][]uint8, p.size)
+	for y := range grid {
+		grid[y] = make([]uint8, p.size)
+		for x := range grid[y] {
+			for v := uint8(1); v <= p.size; v++ {
+				if s.value(satLit(p.cellVar(Index{uint8(x), uint8(y)}, v))) > 0 {
+					grid[y][x] = v
+				}
+			}
+		}
+	}
+	return grid
+}
+
+// SAT is a Solver using the built in SAT solver, with the encoding written
+// by WriteDIMACS. It needs no external programs, and gives the same solution
+// as Backtrack for puzzles that have only one. FailedPaths in its stats
+// counts conflicts.
+type SAT struct{}
+
+func (SAT) Solve(ctx context.Context, p *Puzzle) (Result, error) {
+	result := Result{Solver: "sat"}
+	if err := p.Validate(); err != nil {
+		return result, err
+	}
+	start := time.Now()
+	s := p.satFor()
+	found, err := s.solve(ctx)
+	result.Stats = SolveStats{FailedPaths: s.conflicts, Duration: time.Since(start)}
+	if err != nil {
+		return result, StoppedError{err, result.Stats}
+	}
+	if !found {
+		return result, UnsolveableError{s.conflicts}
+	}
+	return result, p.fill(p.satGrid(s))
+}
+
+// SolveSAT fills in the puzzle's solution using the SAT solver.
+func (p *Puzzle) SolveSAT() error {
+	_, err := SAT{}.Solve(context.Background(), p)
+	return err
+}
+
+// Count rules out each solution found with a new clause before looking for
+// the next.
+func (SAT) Count(ctx context.Context, p *Puzzle, limit int) (int, error) {
+	if err := p.Validate(); err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	s := p.satFor()
+	count := 0
+	for limit <= 0 || count < limit {
+		found, err := s.solve(ctx)
+		if err != nil {
+			stats := SolveStats{FailedPaths: s.conflicts, Duration: time.Since(start)}
+			return count, StoppedError{err, stats}
+		}
+		if !found {
+			break
+		}
+		count++
+		grid := p.satGrid(s)
+		block := make([]int, 0, len(grid)*len(grid))
+		for y := range grid {
+			for x, v := range grid[y] {
+				block = append(block, -p.cellVar(Index{uint8(x), uint8(y)}, v))
+			}
+		}
+		s.cancelUntil(0)
+		s.addClause(block)
+	}
+	return count, nil
+}
+
+// CountSolutionsSAT is CountSolutions using the SAT solver.
+func (p *Puzzle) CountSolutionsSAT(limit int) (int, error) {
+	return SAT{}.Count(context.Background(), p, limit)
+}
@@ -0,0 +1,217 @@
+package kenken
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func satisfiable(numVars int, clauses [][]int) bool {
+	for m := 0; m < 1<<numVars; m++ {
+		if satisfies(clauses, func(v int) bool { return m&(1<<(v-1)) != 0 }) {
+			return true
+		}
+	}
+	return false
+}
+
+func satisfies(clauses [][]int, value func(v int) bool) bool {
+	for _, c := range clauses {
+		ok := false
+		for _, lit := range c {
+			if (lit > 0) == value(max(lit, -lit)) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSATSolverRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	const numVars = 12
+	sat, unsat := 0, 0
+	for test := 0; test < 300; test++ {
+		clauses := make([][]int, 40+rng.Intn(30))
+		for i := range clauses {
+			clauses[i] = make([]int, 3)
+			for k := range clauses[i] {
+				clauses[i][k] = 1 + rng.Intn(numVars)
+				if rng.Intn(2) == 0 {
+					clauses[i][k] = -clauses[i][k]
+				}
+			}
+		}
+		s := newSATSolver(numVars)
+		for _, c := range clauses {
+			s.addClause(c)
+		}
+		found, _ := s.solve(context.Background())
+		if found != satisfiable(numVars, clauses) {
+			t.Fatalf("Solver said %v for %v", found, clauses)
+		}
+		if found {
+			sat++
+			if !satisfies(clauses, func(v int) bool { return s.value(satLit(v)) > 0 }) {
+				t.Fatalf("Model does not satisfy %v", clauses)
+			}
+		} else {
+			unsat++
+		}
+	}
+	if sat == 0 || unsat == 0 {
+		t.Errorf("Expected a mix of results, got %v sat and %v unsat", sat, unsat)
+	}
+}
+
+// pigeonhole returns a solver for putting six pigeons in five holes, with
+// variable 5*i+j+1 for pigeon i in hole j.
+func pigeonhole() *satSolver {
+	const pigeons, holes = 6, 5
+	s := newSATSolver(pigeons * holes)
+	for i := 0; i < pigeons; i++ {
+		c := make([]int, holes)
+		for j := range c {
+			c[j] = holes*i + j + 1
+		}
+		s.addClause(c)
+	}
+	for j := 0; j < holes; j++ {
+		for a := 0; a < pigeons; a++ {
+			for b := a + 1; b < pigeons; b++ {
+				s.addClause([]int{-(holes*a + j + 1), -(holes*b + j + 1)})
+			}
+		}
+	}
+	return s
+}
+
+func TestSATSolverPigeonhole(t *testing.T) {
+	s := pigeonhole()
+	if found, err := s.solve(context.Background()); found || err != nil || s.conflicts == 0 {
+		t.Errorf("Pigeonhole formula was solved, or refuted without conflicts: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := pigeonhole().solve(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancellation, got: %v", err)
+	}
+}
+
+func TestSATSolverStopsWithoutConflicts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := newSATSolver(3)
+	s.addClause([]int{1, 2, 3})
+	if found, err := s.solve(ctx); found || !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancellation before the first decision, got %v: %v", found, err)
+	}
+}
+
+func TestSATSolverReducesLearnts(t *testing.T) {
+	s := pigeonhole()
+	s.maxLearnts = 10
+	if found, err := s.solve(context.Background()); found || err != nil {
+		t.Errorf("Pigeonhole formula was solved: %v", err)
+	}
+	if s.maxLearnts == 10 {
+		t.Errorf("No learnt clauses were deleted after %v conflicts", s.conflicts)
+	}
+
+	p, _ := Parse(strings.NewReader(hardText))
+	s = p.satFor()
+	s.maxLearnts = 10
+	if found, err := s.solve(context.Background()); !found || err != nil {
+		t.Fatalf("Failed to solve with few learnt clauses: %v", err)
+	}
+	if err := p.fill(p.satGrid(s)); err != nil {
+		t.Errorf("Found a wrong solution with few learnt clauses: %v", err)
+	}
+}
+
+func TestLuby(t *testing.T) {
+	expected := []int{1, 1, 2, 1, 1, 2, 4, 1, 1, 2, 1, 1, 2, 4, 8, 1}
+	for i, e := range expected {
+		if l := luby(i); l != e {
+			t.Errorf("luby(%v) was %v, expected %v", i, l, e)
+		}
+	}
+}
+
+func TestSolveSAT(t *testing.T) {
+	builders := []*PuzzleBuilder{examplePuzzleBuilder(), examplePuzzle2Builder()}
+	sols := [][][]uint8{exampleSolution(), exampleSolution2()}
+	for i, b := range builders {
+		p, _ := b.Build()
+		if err := p.SolveSAT(); err != nil {
+			t.Fatalf("Failed to solve example %v: %v", i, err)
+		}
+		if !sameGrid(p.Grid(), sols[i]) {
+			t.Errorf("Example %v was solved as %v, expected %v", i, p.Grid(), sols[i])
+		}
+	}
+	for size := uint8(3); size <= 7; size++ {
+		for seed := int64(0); seed < 3; seed++ {
+			p, _ := Generate(GeneratorOptions{Size: size, Seed: seed})
+			q := p.Clone()
+			p.Solve()
+			if err := q.SolveSAT(); err != nil {
+				t.Fatalf("SolveSAT failed: %v", err)
+			}
+			if !sameGrid(p.Grid(), q.Grid()) {
+				t.Errorf("Solutions differ for size %v seed %v:\n%v\n%v", size, seed, p, q)
+			}
+		}
+	}
+	p, _ := Parse(strings.NewReader(hardText))
+	if err := p.SolveSAT(); err != nil || !p.isSolved() {
+		t.Errorf("Failed to solve the hard puzzle: %v", err)
+	}
+}
+
+func TestSolveSATUnsolveable(t *testing.T) {
+	p, _ := Parse(strings.NewReader(backtrackingText))
+	var unsolveable UnsolveableError
+	if err := p.SolveSAT(); err == nil || !errors.As(err, &unsolveable) {
+		t.Errorf("Expected an UnsolveableError, got: %v", err)
+	}
+}
+
+func TestCountSolutionsSAT(t *testing.T) {
+	p, _ := NewPuzzleBuilder(3).
+		AddCage(Sum, 6, Index{0, 0}, Index{1, 0}, Index{2, 0}).
+		AddCage(Sum, 6, Index{0, 1}, Index{1, 1}, Index{2, 1}).
+		AddCage(Sum, 6, Index{0, 2}, Index{1, 2}, Index{2, 2}).
+		Build()
+	expected, _ := p.CountSolutions(0)
+	if count, err := p.CountSolutionsSAT(0); count != expected || err != nil {
+		t.Errorf("Counted %v solutions, expected %v: %v", count, expected, err)
+	}
+	if count, _ := p.CountSolutionsSAT(5); count != 5 {
+		t.Errorf("Counted %v solutions with a limit of 5", count)
+	}
+	p, _ = Parse(strings.NewReader(backtrackingText))
+	if count, _ := p.CountSolutionsSAT(0); count != 0 {
+		t.Errorf("Counted %v solutions of an unsolveable puzzle", count)
+	}
+}
+
+func BenchmarkSolveSATHard(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		p, _ := Parse(strings.NewReader(hardText))
+		p.SolveSAT()
+	}
+}
+
+func BenchmarkSolveSATLargeCages(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		p, _ := Parse(strings.NewReader(largeCageText))
+		p.SolveSAT()
+	}
+}
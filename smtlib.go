@@ -0,0 +1,217 @@
+package kenken
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// smtVar names the integer variable holding the value at i.
+func smtVar(i Index) string {
+	return fmt.Sprintf("c_%v_%v", i.X, i.Y)
+}
+
+// smtApply returns the term applying op to args. Sums and products of a
+// single term are just the term, as SMT-LIB needs two arguments for them.
+func smtApply(op string, args ...string) string {
+	if len(args) == 1 && (op == "+" || op == "*") {
+		return args[0]
+	}
+	return "(" + op + " " + strings.Join(args, " ") + ")"
+}
+
+// smtRegion returns the assertion that the region's values satisfy its
+// operation. As in CombinationTable.Maps, a Sub or Div cage with more than
+// two cells is satisfied if one of its values, less the sum of the others or
+// divided by their product, gives the result.
+func smtRegion(r *Region) string {
+	cells := r.indices.SortedSlice()
+	vars := make([]string, len(cells))
+	for k, c := range cells {
+		vars[k] = smtVar(c)
+	}
+	result := strconv.FormatUint(uint64(r.result), 10)
+	switch r.op {
+	case Sum:
+		return smtApply("=", smtApply("+", vars...), result)
+	case Mul:
+		return smtApply("=", smtApply("*", vars...), result)
+	case Nothing:
+		return smtApply("=", vars[0], result)
+	}
+	choices := make([]string, len(vars))
+	for k := range vars {
+		others := make([]string, 0, len(vars)-1)
+		others = append(others, vars[:k]...)
+		others = append(others, vars[k+1:]...)
+		if r.op == Sub {
+			choices[k] = smtApply("=", smtApply("-", append([]string{vars[k]}, others...)...), result)
+		} else {
+			choices[k] = smtApply("=", vars[k], smtApply("*", append([]string{result}, others...)...))
+		}
+	}
+	return smtApply("or", choices...)
+}
+
+// WriteSMTLIB writes the puzzle as an SMT-LIB2 script over integers, with one
+// variable c_x_y per cell. The script ends with (check-sat) and (get-model);
+// ReadSMTModel turns the model back into a solution.
+func WriteSMTLIB(w io.Writer, p *Puzzle) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "; kenken puzzle of size %v\n", p.size)
+	fmt.Fprintln(bw, "; c_x_y is the value at (x, y), with y=0 the bottom row")
+	fmt.Fprintln(bw, "(set-logic QF_NIA)")
+	rows := make([][]string, p.size)
+	cols := make([][]string, p.size)
+	for y := uint8(0); y < p.size; y++ {
+		for x := uint8(0); x < p.size; x++ {
+			v := smtVar(Index{x, y})
+			rows[y] = append(rows[y], v)
+			cols[x] = append(cols[x], v)
+			fmt.Fprintf(bw, "(declare-const %v Int)\n", v)
+			fmt.Fprintf(bw, "(assert (and (<= 1 %v) (<= %v %v)))\n", v, v, p.size)
+			if set := p.GetValue(Index{x, y}); set != 0 {
+				fmt.Fprintf(bw, "(assert (= %v %v))\n", v, set)
+			}
+		}
+	}
+	if p.size > 1 {
+		for _, house := range append(rows, cols...) {
+			fmt.Fprintf(bw, "(assert %v)\n", smtApply("distinct", house...))
+		}
+	}
+	for i := range p.regions {
+		fmt.Fprintf(bw, "(assert %v)\n", smtRegion(&p.regions[i]))
+	}
+	fmt.Fprintln(bw, "(check-sat)")
+	fmt.Fprintln(bw, "(get-model)")
+	return bw.Flush()
+}
+
+// sexpr is an atom, or a list if atom is empty.
+type sexpr struct {
+	atom string
+	list []sexpr
+}
+
+// parseSExprs reads a sequence of s-expressions, skipping comments.
+func parseSExprs(r io.Reader) ([]sexpr, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	stack := [][]sexpr{nil}
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == ';':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+		case c == '(':
+			stack = append(stack, []sexpr{})
+		case c == ')':
+			if len(stack) == 1 {
+				return nil, fmt.Errorf("smt: unexpected ')' at offset %v", i)
+			}
+			list := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			stack[len(stack)-1] = append(stack[len(stack)-1], sexpr{list: list})
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		default:
+			start := i
+			if c == '|' {
+				for i++; i < len(data) && data[i] != '|'; i++ {
+				}
+				if i == len(data) {
+					return nil, fmt.Errorf("smt: unterminated symbol at offset %v", start)
+				}
+				i++
+			} else {
+				for i < len(data) && !strings.ContainsRune("() \t\r\n;", rune(data[i])) {
+					i++
+				}
+			}
+			atom := strings.Trim(string(data[start:i]), "|")
+			stack[len(stack)-1] = append(stack[len(stack)-1], sexpr{atom: atom})
+			i--
+		}
+	}
+	if len(stack) != 1 {
+		return nil, fmt.Errorf("smt: unexpected end of input")
+	}
+	return stack[0], nil
+}
+
+// smtInt returns the value of an integer constant such as 3 or (- 3).
+func smtInt(e sexpr) (int, error) {
+	if len(e.list) == 2 && e.list[0].atom == "-" {
+		v, err := smtInt(e.list[1])
+		return -v, err
+	}
+	return strconv.Atoi(e.atom)
+}
+
+// ReadSMTModel fills in the puzzle from a solver's answer to the script
+// written by WriteSMTLIB: "sat" followed by the model. It returns an
+// UnsolveableError if the answer is "unsat", and an error if the model is
+// not a solution.
+func ReadSMTModel(r io.Reader, p *Puzzle) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	exprs, err := parseSExprs(r)
+	if err != nil {
+		return err
+	}
+	cells := make(map[string]Index)
+	grid := make([][]uint8, p.size)
+	for y := range grid {
+		grid[y] = make([]uint8, p.size)
+		for x := range grid[y] {
+			cells[smtVar(Index{uint8(x), uint8(y)})] = Index{uint8(x), uint8(y)}
+		}
+	}
+	var visit func(e sexpr) error
+	visit = func(e sexpr) error {
+		if len(e.list) == 5 && e.list[0].atom == "define-fun" {
+			i, present := cells[e.list[1].atom]
+			if !present {
+				return nil
+			}
+			v, err := smtInt(e.list[4])
+			if err != nil || v < 1 || v > int(p.size) {
+				return fmt.Errorf("smt: invalid value for %v", e.list[1].atom)
+			}
+			grid[i.Y][i.X] = uint8(v)
+			return nil
+		}
+		for _, inner := range e.list {
+			if err := visit(inner); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, e := range exprs {
+		switch e.atom {
+		case "unsat":
+			return UnsolveableError{}
+		case "sat", "":
+		default:
+			return fmt.Errorf("smt: solver answered %q", e.atom)
+		}
+		if err := visit(e); err != nil {
+			return err
+		}
+	}
+	if err := p.fill(grid); err != nil {
+		return fmt.Errorf("smt: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package kenken
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// evalSMT evaluates a term of the scripts written by WriteSMTLIB, with true
+// as 1 and false as 0.
+func evalSMT(t *testing.T, e sexpr, env map[string]int) int {
+	t.Helper()
+	if e.list == nil {
+		if v, present := env[e.atom]; present {
+			return v
+		}
+		v, err := smtInt(e)
+		if err != nil {
+			t.Fatalf("Unknown atom %q", e.atom)
+		}
+		return v
+	}
+	args := make([]int, len(e.list)-1)
+	for k, a := range e.list[1:] {
+		args[k] = evalSMT(t, a, env)
+	}
+	truth := func(b bool) int {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	v := args[0]
+	switch op := e.list[0].atom; op {
+	case "+", "-", "*":
+		for _, a := range args[1:] {
+			switch op {
+			case "+":
+				v += a
+			case "-":
+				v -= a
+			case "*":
+				v *= a
+			}
+		}
+		return v
+	case "and", "or":
+		for _, a := range args[1:] {
+			if op == "and" {
+				v &= a
+			} else {
+				v |= a
+			}
+		}
+		return v
+	case "=":
+		return truth(args[0] == args[1])
+	case "<=":
+		return truth(args[0] <= args[1])
+	case "distinct":
+		seen := make(map[int]bool)
+		for _, a := range args {
+			if seen[a] {
+				return 0
+			}
+			seen[a] = true
+		}
+		return 1
+	}
+	t.Fatalf("Unknown operator %q", e.list[0].atom)
+	return 0
+}
+
+func TestSMTRegionMatchesCombinations(t *testing.T) {
+	const size = 6
+	cells := []Index{{0, 0}, {1, 0}, {1, 1}}
+	for _, op := range []Operation{Sum, Sub, Mul, Div} {
+		for n := 2; n <= len(cells); n++ {
+			for result := uint(1); result <= 30; result++ {
+				r := NewRegion(op, result, cells[:n]...)
+				exprs, err := parseSExprs(strings.NewReader(smtRegion(r)))
+				if err != nil {
+					t.Fatalf("Failed to parse %v: %v", smtRegion(r), err)
+				}
+				maps := r.GetPossibleMaps(size)
+				values := make([]int, n)
+				var try func(k int)
+				try = func(k int) {
+					if k == n {
+						env := make(map[string]int)
+						m := NewByteMap()
+						for j, c := range cells[:n] {
+							env[smtVar(c)] = values[j]
+							m.Add(byte(values[j]))
+						}
+						if (evalSMT(t, exprs[0], env) == 1) != maps.Contains(m) {
+							t.Errorf("%v %v disagrees with the combinations for %v", op, result, values)
+						}
+						return
+					}
+					for v := 1; v <= size; v++ {
+						values[k] = v
+						try(k + 1)
+					}
+				}
+				try(0)
+			}
+		}
+	}
+}
+
+func TestWriteSMTLIB(t *testing.T) {
+	p, _ := examplePuzzleBuilder().Build()
+	var sb strings.Builder
+	if err := WriteSMTLIB(&sb, p); err != nil {
+		t.Fatalf("WriteSMTLIB failed: %v", err)
+	}
+	exprs, err := parseSExprs(strings.NewReader(sb.String()))
+	if err != nil {
+		t.Fatalf("Failed to parse the script: %v", err)
+	}
+	env := make(map[string]int)
+	for y, row := range exampleSolution() {
+		for x, v := range row {
+			env[smtVar(Index{uint8(x), uint8(y)})] = int(v)
+		}
+	}
+	wrong := make(map[string]int)
+	for name, v := range env {
+		wrong[name] = v
+	}
+	wrong["c_0_0"], wrong["c_1_0"] = env["c_1_0"], env["c_0_0"]
+	asserts, failed := 0, false
+	for _, e := range exprs {
+		if e.list[0].atom != "assert" {
+			continue
+		}
+		asserts++
+		if evalSMT(t, e.list[1], env) != 1 {
+			t.Errorf("The solution breaks an assertion in:\n%v", sb.String())
+		}
+		failed = failed || evalSMT(t, e.list[1], wrong) != 1
+	}
+	if expected := 25 + 10 + len(p.regions); asserts != expected {
+		t.Errorf("Wrote %v assertions, expected %v", asserts, expected)
+	}
+	if !failed {
+		t.Errorf("A wrong grid satisfied every assertion")
+	}
+}
+
+func TestReadSMTModel(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("sat\n(\n")
+	for y, row := range exampleSolution() {
+		for x, v := range row {
+			fmt.Fprintf(&sb, "  (define-fun %v () Int\n    %v)\n", smtVar(Index{uint8(x), uint8(y)}), v)
+		}
+	}
+	sb.WriteString(")\n")
+	p, _ := examplePuzzleBuilder().Build()
+	if err := ReadSMTModel(strings.NewReader(sb.String()), p); err != nil {
+		t.Fatalf("ReadSMTModel failed: %v", err)
+	}
+	if !sameGrid(p.Grid(), exampleSolution()) {
+		t.Errorf("Read %v, expected %v", p.Grid(), exampleSolution())
+	}
+
+	var unsolveable UnsolveableError
+	p, _ = examplePuzzleBuilder().Build()
+	if err := ReadSMTModel(strings.NewReader("unsat\n"), p); !errors.As(err, &unsolveable) {
+		t.Errorf("Expected an UnsolveableError, got: %v", err)
+	}
+	bad := []string{
+		"sat\n((define-fun c_0_0 () Int 3))\n",
+		"sat\n((define-fun c_0_0 () Int (- 3)))\n",
+		"unknown\n",
+		"sat\n((define-fun c_0_0 () Int 3)\n",
+		strings.Replace(sb.String(), "c_0_0 () Int\n    3", "c_0_0 () Int\n    2", 1),
+	}
+	for _, out := range bad {
+		p, _ = examplePuzzleBuilder().Build()
+		if err := ReadSMTModel(strings.NewReader(out), p); err == nil {
+			t.Errorf("Accepted model %q", out)
+		}
+	}
+}
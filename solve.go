@@ -0,0 +1,173 @@
+package kenken
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SolveOptions controls SolveContext. The zero value sets no limits.
+type SolveOptions struct {
+	// Observer, if set, is told about each step of the search.
+	Observer SolveObserver
+	// MaxNodes stops the search once it has visited this many nodes. 0 means
+	// no limit.
+	MaxNodes uint
+	// MaxBacktracks stops the search once it has taken back this many values.
+	// 0 means no limit.
+	MaxBacktracks uint
+}
+
+// SolveStats counts the work done by a solve.
+type SolveStats struct {
+	// Nodes is the number of times the search recursed.
+	Nodes uint
+	// ValuesTried is the number of values considered for a box, including
+	// those rejected by their region.
+	ValuesTried uint
+	// RegionRejections is the number of values ruled out because their region
+	// couldn't then be completed.
+	RegionRejections uint
+	// Eliminations is the number of candidates removed from rows and columns.
+	Eliminations uint
+	// Backtracks is the number of values taken back out of a box.
+	Backtracks uint
+	// MaxDepth is the largest number of boxes filled at once.
+	MaxDepth uint
+	// HeapOps is the number of pushes, pops and fixes of the box heap.
+	HeapOps uint
+	// FailedPaths matches the count in UnsolveableError.
+	FailedPaths uint
+	Duration    time.Duration
+}
+
+var (
+	ErrNodeBudget      = errors.New("node budget exhausted")
+	ErrBacktrackBudget = errors.New("backtrack budget exhausted")
+)
+
+// StoppedError is returned when a solve is cancelled or runs out of budget
+// before finding a solution. Reason is the context's error, ErrNodeBudget or
+// ErrBacktrackBudget.
+type StoppedError struct {
+	Reason error
+	Stats  SolveStats
+}
+
+func (e StoppedError) Error() string {
+	return fmt.Sprintf("Stopped solving puzzle after %v nodes: %v", e.Stats.Nodes, e.Reason)
+}
+
+func (e StoppedError) Unwrap() error {
+	return e.Reason
+}
+
+// solveRun holds the state of a single call to SolveContext.
+type solveRun struct {
+	ctx   context.Context
+	opts  SolveOptions
+	stats SolveStats
+	// err is set once the search has to stop.
+	err error
+}
+
+// Solve fills in the puzzle's solution. Any observers are told about each
+// step of the search.
+func (p *Puzzle) Solve(observers ...SolveObserver) error {
+	opts := SolveOptions{}
+	if len(observers) > 0 {
+		opts.Observer = multiObserver(observers)
+	}
+	_, err := p.SolveContext(context.Background(), opts)
+	return err
+}
+
+// SolveContext fills in the puzzle's solution, giving up with a StoppedError
+// if ctx is done or a budget in opts runs out. A stopped puzzle is left as it
+// was. The stats are returned whether or not a solution was found.
+func (p *Puzzle) SolveContext(ctx context.Context, opts SolveOptions) (SolveStats, error) {
+	result, err := Backtrack{opts}.Solve(ctx, p)
+	return result.Stats, err
+}
+
+// enterNode counts a node of the search. It returns false if the search has
+// to stop instead.
+func (p *Puzzle) enterNode() bool {
+	if p.run == nil {
+		return true
+	}
+	r := p.run
+	if r.err == nil {
+		if err := r.ctx.Err(); err != nil {
+			r.err = err
+		} else if r.opts.MaxNodes > 0 && r.stats.Nodes >= r.opts.MaxNodes {
+			r.err = ErrNodeBudget
+		}
+	}
+	if r.err != nil {
+		return false
+	}
+	depth := int(p.size)*int(p.size) - p.heap.Len()
+	r.stats.Nodes++
+	r.stats.MaxDepth = max(r.stats.MaxDepth, uint(depth))
+	p.observe().EnterNode(depth)
+	return true
+}
+
+// backtrack counts a value taken back out of the box at i.
+func (p *Puzzle) backtrack(i Index, v uint8) {
+	if p.run == nil {
+		return
+	}
+	p.observe().Backtrack(i, v)
+	p.run.stats.Backtracks++
+	if p.run.opts.MaxBacktracks > 0 && p.run.stats.Backtracks >= p.run.opts.MaxBacktracks && p.run.err == nil {
+		p.run.err = ErrBacktrackBudget
+	}
+}
+
+// tryValue counts a value tried in the box at i.
+func (p *Puzzle) tryValue(i Index, v uint8) {
+	if p.run != nil {
+		p.run.stats.ValuesTried++
+		p.observe().SetValue(i, v)
+	}
+}
+
+// rejectCandidate counts v being ruled out for the box at i because it
+// can't complete the region.
+func (p *Puzzle) rejectCandidate(i Index, v uint8, r *Region) {
+	if p.run != nil {
+		p.run.stats.RegionRejections++
+		p.observe().RejectRegion(i, v, r)
+	}
+}
+
+// countHeapOps counts n operations on the box heap.
+func (p *Puzzle) countHeapOps(n uint) {
+	if p.run != nil {
+		p.run.stats.HeapOps += n
+	}
+}
+
+// deleteCandidate counts v being ruled out for the box at i.
+func (p *Puzzle) deleteCandidate(i Index, v uint8) {
+	if p.run != nil {
+		p.run.stats.Eliminations++
+		p.observe().DeleteCandidate(i, v)
+	}
+}
+
+// stopped reports whether the search has to stop.
+func (p *Puzzle) stopped() bool {
+	return p.run != nil && p.run.err != nil
+}
+
+// observe returns the observer of the current solve.
+func (p *Puzzle) observe() SolveObserver {
+	if p.run == nil || p.run.opts.Observer == nil {
+		return NoopObserver{}
+	}
+	return p.run.opts.Observer
+}
@@ -0,0 +1,85 @@
+package kenken
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSolveContextCancelled(t *testing.T) {
+	p, _ := examplePuzzleBuilder().Build()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := p.SolveContext(ctx, SolveOptions{})
+	var stopped StoppedError
+	if !errors.As(err, &stopped) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected a cancelled StoppedError, got: %v", err)
+	}
+	if stopped.Stats.Nodes != 0 {
+		t.Errorf("Cancelled solve visited %v nodes", stopped.Stats.Nodes)
+	}
+	if err := p.Solve(); err != nil {
+		t.Errorf("Puzzle couldn't be solved after a cancelled solve: %v", err)
+	}
+}
+
+func TestSolveContextNodeBudget(t *testing.T) {
+	p, _ := examplePuzzleBuilder().Build()
+	_, err := p.SolveContext(context.Background(), SolveOptions{MaxNodes: 3})
+	var stopped StoppedError
+	if !errors.As(err, &stopped) || !errors.Is(err, ErrNodeBudget) {
+		t.Fatalf("Expected ErrNodeBudget, got: %v", err)
+	}
+	if stopped.Stats.Nodes != 3 {
+		t.Errorf("Expected 3 nodes, got %v", stopped.Stats.Nodes)
+	}
+	for y := uint8(0); y < p.Size(); y++ {
+		for x := uint8(0); x < p.Size(); x++ {
+			if p.GetValue(Index{x, y}) != 0 {
+				t.Fatalf("Stopped solve left a value at %v", Index{x, y})
+			}
+		}
+	}
+	if _, err := p.SolveContext(context.Background(), SolveOptions{MaxNodes: 10000}); err != nil {
+		t.Errorf("Solve with a large budget failed: %v", err)
+	}
+}
+
+// backtrackingText has no solution, but propagation alone can't show it.
+const backtrackingText = "EEIIF\nAAAIF\nDHHHF\nDHGGB\nCCGBB\nA 10+\nB 11+\nC 2-\nD 2*\nE 20*\nF 6+\nG 9+\nH 60*\nI 7+\n"
+
+func TestSolveContextBacktrackBudget(t *testing.T) {
+	p, _ := Parse(strings.NewReader(backtrackingText))
+	_, err := p.SolveContext(context.Background(), SolveOptions{MaxBacktracks: 1})
+	var stopped StoppedError
+	if !errors.As(err, &stopped) || !errors.Is(err, ErrBacktrackBudget) {
+		t.Fatalf("Expected ErrBacktrackBudget, got: %v", err)
+	}
+	// Unwinding the search takes back the values above the one that
+	// exhausted the budget too.
+	if stopped.Stats.Backtracks < 1 || stopped.Stats.Backtracks > stopped.Stats.MaxDepth {
+		t.Errorf("Expected 1 backtrack and the unwinding after it, got %v", stopped.Stats.Backtracks)
+	}
+	var unsolveable UnsolveableError
+	if err := p.Solve(); !errors.As(err, &unsolveable) {
+		t.Errorf("Expected an UnsolveableError without a budget, got: %v", err)
+	}
+}
+
+func TestSolveStats(t *testing.T) {
+	p, _ := examplePuzzleBuilder().Build()
+	stats, err := p.SolveContext(context.Background(), SolveOptions{})
+	if err != nil {
+		t.Fatalf("Failed to solve puzzle: %v", err)
+	}
+	if stats.Nodes != stats.ValuesTried+1 {
+		t.Errorf("Expected a node for each value tried plus the root: %+v", stats)
+	}
+	if stats.MaxDepth != 25 || stats.Eliminations == 0 || stats.HeapOps == 0 || stats.Duration <= 0 {
+		t.Errorf("Stats are missing counts: %+v", stats)
+	}
+	if stats.Backtracks != stats.ValuesTried-25 {
+		t.Errorf("Expected every value but the solution to be taken back: %+v", stats)
+	}
+}
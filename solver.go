@@ -0,0 +1,82 @@
+package kenken
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Result describes a call to Solver.Solve.
+type Result struct {
+	// Solver names the strategy that was used.
+	Solver string
+	// Stats counts the work done. Solvers fill in the counts that make sense
+	// for them, and always Duration.
+	Stats SolveStats
+}
+
+// Solver is a strategy for solving puzzles. Solve fills in the puzzle's
+// solution, or returns an error and leaves the puzzle as it was: an
+// UnsolveableError if there is no solution, or a StoppedError if ctx is done
+// first. Solvers may be used by several goroutines at once, each with its own
+// puzzle.
+type Solver interface {
+	Solve(ctx context.Context, p *Puzzle) (Result, error)
+}
+
+// Counter is implemented by solvers that can also count a puzzle's
+// solutions. Count returns the number of solutions, stopping once limit have
+// been found, or counting them all if limit is 0. It leaves the puzzle as it
+// was, and returns a StoppedError with the count so far if ctx is done first.
+type Counter interface {
+	Count(ctx context.Context, p *Puzzle, limit int) (int, error)
+}
+
+var registry = struct {
+	sync.RWMutex
+	solvers map[string]Solver
+}{solvers: make(map[string]Solver)}
+
+func init() {
+	RegisterSolver("backtrack", Backtrack{})
+	RegisterSolver("parallel", Parallel{})
+	RegisterSolver("dlx", DLX{})
+	RegisterSolver("sat", SAT{})
+}
+
+// RegisterSolver makes s available by name through GetSolver. It panics if
+// the name is already taken.
+func RegisterSolver(name string, s Solver) {
+	registry.Lock()
+	defer registry.Unlock()
+	if _, present := registry.solvers[name]; present {
+		panic(fmt.Sprintf("kenken: solver %q registered twice", name))
+	}
+	registry.solvers[name] = s
+}
+
+// GetSolver returns the solver registered under name. The built in solvers
+// are "backtrack", the default used by Puzzle.Solve, "parallel", "dlx" and
+// "sat".
+func GetSolver(name string) (Solver, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+	s, present := registry.solvers[name]
+	if !present {
+		return nil, fmt.Errorf("unknown solver %q", name)
+	}
+	return s, nil
+}
+
+// SolverNames returns the names of every registered solver, sorted.
+func SolverNames() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	names := make([]string, 0, len(registry.solvers))
+	for name := range registry.solvers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
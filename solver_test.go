@@ -0,0 +1,128 @@
+package kenken
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBuiltInSolvers(t *testing.T) {
+	names := SolverNames()
+	for _, name := range []string{"backtrack", "dlx", "parallel", "sat"} {
+		if !slices.Contains(names, name) {
+			t.Fatalf("Solver %q is not registered: %v", name, names)
+		}
+		s, err := GetSolver(name)
+		if err != nil {
+			t.Fatalf("GetSolver(%q) failed: %v", name, err)
+		}
+
+		p, _ := examplePuzzleBuilder().Build()
+		result, err := s.Solve(context.Background(), p)
+		if err != nil {
+			t.Fatalf("%v failed: %v", name, err)
+		}
+		if result.Solver != name {
+			t.Errorf("%v reported itself as %q", name, result.Solver)
+		}
+		if !sameGrid(p.Grid(), exampleSolution()) {
+			t.Errorf("%v solved the example as %v", name, p.Grid())
+		}
+
+		p, _ = Parse(strings.NewReader(backtrackingText))
+		var unsolveable UnsolveableError
+		if _, err := s.Solve(context.Background(), p); !errors.As(err, &unsolveable) {
+			t.Errorf("Expected an UnsolveableError from %v, got: %v", name, err)
+		}
+	}
+	if !slices.IsSorted(names) {
+		t.Errorf("Solver names are not sorted: %v", names)
+	}
+}
+
+func TestSolversStop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, s := range []Solver{Backtrack{}, Parallel{}, DLX{}, SAT{}} {
+		p, _ := Parse(strings.NewReader(hardText))
+		var stopped StoppedError
+		if _, err := s.Solve(ctx, p); !errors.As(err, &stopped) || !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected %T to stop, got: %v", s, err)
+		}
+		if p.GetValue(Index{0, 0}) != 0 {
+			t.Errorf("%T changed a stopped puzzle", s)
+		}
+	}
+}
+
+func TestBuiltInCounters(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, name := range []string{"backtrack", "dlx", "parallel", "sat"} {
+		s, _ := GetSolver(name)
+		c, ok := s.(Counter)
+		if !ok {
+			t.Fatalf("%v can't count solutions", name)
+		}
+		p, _ := NewPuzzleBuilder(3).
+			AddCage(Sum, 6, Index{0, 0}, Index{1, 0}, Index{2, 0}).
+			AddCage(Sum, 6, Index{0, 1}, Index{1, 1}, Index{2, 1}).
+			AddCage(Sum, 6, Index{0, 2}, Index{1, 2}, Index{2, 2}).
+			Build()
+		if count, err := c.Count(context.Background(), p, 0); count != 12 || err != nil {
+			t.Errorf("%v counted %v solutions, expected 12: %v", name, count, err)
+		}
+		if count, err := c.Count(context.Background(), p, 5); count != 5 || err != nil {
+			t.Errorf("%v counted %v solutions with a limit of 5: %v", name, count, err)
+		}
+		if p.GetValue(Index{0, 0}) != 0 {
+			t.Errorf("%v changed the puzzle it counted", name)
+		}
+
+		p, _ = Parse(strings.NewReader(hardText))
+		var stopped StoppedError
+		if _, err := c.Count(cancelled, p, 0); !errors.As(err, &stopped) || !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected %v to stop counting, got: %v", name, err)
+		}
+	}
+	if _, ok := Solver(givenUpSolver{}).(Counter); ok {
+		t.Errorf("A solver without Count was a Counter")
+	}
+}
+
+type givenUpSolver struct{}
+
+func (givenUpSolver) Solve(ctx context.Context, p *Puzzle) (Result, error) {
+	return Result{Solver: "given-up"}, UnsolveableError{}
+}
+
+// unregisterSolver undoes RegisterSolver, so tests leave the registry as
+// they found it.
+func unregisterSolver(name string) {
+	registry.Lock()
+	defer registry.Unlock()
+	delete(registry.solvers, name)
+}
+
+func TestRegisterSolver(t *testing.T) {
+	RegisterSolver("given-up", givenUpSolver{})
+	t.Cleanup(func() { unregisterSolver("given-up") })
+	s, err := GetSolver("given-up")
+	if err != nil {
+		t.Fatalf("Registered solver was not found: %v", err)
+	}
+	if result, _ := s.Solve(context.Background(), nil); result.Solver != "given-up" {
+		t.Errorf("Got the wrong solver: %+v", result)
+	}
+	if _, err := GetSolver("missing"); err == nil {
+		t.Errorf("Found a solver that was never registered")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Registering a name twice did not panic")
+		}
+	}()
+	RegisterSolver("backtrack", givenUpSolver{})
+}
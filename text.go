@@ -0,0 +1,196 @@
+package kenken
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The text format describes a puzzle with a grid of cage labels, one
+// character per cell, followed by one clue line per label:
+//
+//	# Comments and blank lines are ignored.
+//	AAB
+//	CDB
+//	CDD
+//	A 1-
+//	B 3/
+//	C 5+
+//	D 6*
+//
+// The first grid line is the top row of the puzzle, matching Print. A clue is
+// the label, whitespace, then the result followed by one of + - * / or, for a
+// single cell cage, no operation or =.
+
+// labelAlphabet lists the labels used by Write, in the order they're assigned.
+const labelAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789" +
+	"!\"$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+// ParseError reports a syntax problem in the text format. Line and Col are
+// 1-based.
+type ParseError struct {
+	Line int
+	Col  int
+	Msg  string
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("line %v, column %v: %v", e.Line, e.Col, e.Msg)
+}
+
+type textCage struct {
+	op      Operation
+	result  uint
+	hasClue bool
+	first   ParseError
+	cells   []Index
+}
+
+// Parse reads a puzzle in the text format and builds it. Syntax problems are
+// reported as a ParseError; structural problems with the cages as a
+// ValidationError.
+func Parse(r io.Reader) (*Puzzle, error) {
+	scanner := bufio.NewScanner(r)
+	cages := make(map[rune]*textCage)
+	labels := make([]rune, 0)
+	size, row := 0, 0
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if i := strings.IndexRune(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		col := strings.Index(line, trimmed) + 1
+		if size == 0 {
+			size = len([]rune(trimmed))
+			if size > MaxSize {
+				return nil, ParseError{lineNum, col, fmt.Sprintf("grid is %v cells wide, at most %v are supported", size, MaxSize)}
+			}
+		}
+		if row < size {
+			cells := []rune(trimmed)
+			if len(cells) != size {
+				return nil, ParseError{lineNum, col, fmt.Sprintf("grid row has %v cells, expected %v", len(cells), size)}
+			}
+			for x, label := range cells {
+				if unicode.IsSpace(label) || label > unicode.MaxASCII || !unicode.IsPrint(label) {
+					return nil, ParseError{lineNum, col + x, fmt.Sprintf("invalid cage label %q", label)}
+				}
+				cage, present := cages[label]
+				if !present {
+					cage = &textCage{first: ParseError{lineNum, col + x, ""}}
+					cages[label] = cage
+					labels = append(labels, label)
+				}
+				cage.cells = append(cage.cells, Index{uint8(x), uint8(size - 1 - row)})
+			}
+			row++
+			continue
+		}
+		if err := parseClue(trimmed, lineNum, col, cages); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return nil, ParseError{lineNum + 1, 1, "missing cage grid"}
+	}
+	if row < size {
+		return nil, ParseError{lineNum + 1, 1, fmt.Sprintf("grid has %v rows, expected %v", row, size)}
+	}
+	b := NewPuzzleBuilder(uint8(size))
+	for _, label := range labels {
+		cage := cages[label]
+		if !cage.hasClue {
+			err := cage.first
+			err.Msg = fmt.Sprintf("cage %q has no clue", label)
+			return nil, err
+		}
+		b.AddCage(cage.op, cage.result, cage.cells...)
+	}
+	return b.Build()
+}
+
+func parseClue(clue string, line, col int, cages map[rune]*textCage) error {
+	fields := strings.Fields(clue)
+	label := []rune(fields[0])
+	if len(fields) != 2 || len(label) != 1 {
+		return ParseError{line, col, fmt.Sprintf("expected a cage label and a clue, found %q", clue)}
+	}
+	cage, present := cages[label[0]]
+	if !present {
+		return ParseError{line, col, fmt.Sprintf("cage %q is not in the grid", label[0])}
+	}
+	if cage.hasClue {
+		return ParseError{line, col, fmt.Sprintf("cage %q already has a clue", label[0])}
+	}
+	valueCol := col + strings.Index(clue[len(fields[0]):], fields[1]) + len(fields[0])
+	value := fields[1]
+	op := Nothing
+	digits := strings.TrimRightFunc(value, func(r rune) bool { return !unicode.IsDigit(r) })
+	if suffix := value[len(digits):]; suffix != "" {
+		var ok bool
+		op, ok = OperationFromSymbol(suffix)
+		if !ok {
+			return ParseError{line, valueCol + len(digits), fmt.Sprintf("unknown operation %q", suffix)}
+		}
+	}
+	result, err := strconv.ParseUint(digits, 10, 0)
+	if err != nil {
+		return ParseError{line, valueCol, fmt.Sprintf("invalid result %q", digits)}
+	}
+	cage.op, cage.result, cage.hasClue = op, uint(result), true
+	return nil
+}
+
+// Write writes the puzzle in the text format. Cages are labelled in the order
+// they first appear, reading from the top row.
+func Write(w io.Writer, p *Puzzle) error {
+	if len(p.regions) > len(labelAlphabet) {
+		return fmt.Errorf("puzzle has %v regions, the text format supports at most %v", len(p.regions), len(labelAlphabet))
+	}
+	regions := make(map[Index]*Region)
+	for i := range p.regions {
+		for _, idx := range p.regions[i].GetIndices() {
+			regions[idx] = &p.regions[i]
+		}
+	}
+	labels := make(map[*Region]byte)
+	order := make([]*Region, 0, len(p.regions))
+	var sb strings.Builder
+	for y := int(p.size) - 1; y >= 0; y-- {
+		for x := uint8(0); x < p.size; x++ {
+			r, present := regions[Index{x, uint8(y)}]
+			if !present {
+				return fmt.Errorf("cell %v is not in any region", Index{x, uint8(y)})
+			}
+			label, seen := labels[r]
+			if !seen {
+				label = labelAlphabet[len(order)]
+				labels[r] = label
+				order = append(order, r)
+			}
+			sb.WriteByte(label)
+		}
+		sb.WriteString("\n")
+	}
+	for _, r := range order {
+		sb.WriteString(fmt.Sprintf("%c %v", labels[r], r.result))
+		if r.op != Nothing {
+			sb.WriteString(r.op.Symbol())
+		}
+		sb.WriteString("\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
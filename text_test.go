@@ -0,0 +1,142 @@
+package kenken
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const exampleText = `# The first example puzzle
+AAABC
+DDEBC
+FGHIC
+GGHIJ
+KKLLJ
+A 12*
+B 2/
+C 10+
+D 1-
+E 3
+F 2
+G 20*
+H 3+
+I 2-
+J 3-
+K 1-
+L 9+
+`
+
+func TestParseSolve(t *testing.T) {
+	p, err := Parse(strings.NewReader(exampleText))
+	if err != nil {
+		t.Fatalf("Parse failed with error: %v", err)
+	}
+	if err = p.Solve(); err != nil {
+		t.Fatalf("Solve failed with error: %v", err)
+	}
+	s := exampleSolution()
+	for y := uint8(0); y < p.Size(); y++ {
+		for x := uint8(0); x < p.Size(); x++ {
+			if v := p.GetValue(Index{x, y}); v != s[y][x] {
+				t.Errorf("Value at %v was %v, expected %v", Index{x, y}, v, s[y][x])
+			}
+		}
+	}
+}
+
+func TestWriteParseRoundTrip(t *testing.T) {
+	p := examplePuzzle()
+	var buf bytes.Buffer
+	if err := Write(&buf, &p); err != nil {
+		t.Fatalf("Write failed with error: %v", err)
+	}
+	if buf.String() != strings.SplitN(exampleText, "\n", 2)[1] {
+		t.Errorf("Write produced:\n%v\nexpected:\n%v", buf.String(), exampleText)
+	}
+	parsed, err := Parse(&buf)
+	if err != nil {
+		t.Fatalf("Parse failed with error: %v", err)
+	}
+	compareRegions(t, parsed.GetRegions(), p.GetRegions())
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		text      string
+		line, col int
+	}{
+		{"", 1, 1},
+		{"AB\nA\n", 2, 1},
+		{"AB\nAC\nA 3+\nB 1\n", 2, 2},
+		{"AB\n  CC\nA 2+\nB 1\nC 3+\nD 2\n", 6, 1},
+		{"AB\nCC\nA 2+\nB 1\nA 2\n", 5, 1},
+		{"AB\nCC\nA 2+\nB 1x\n", 4, 4},
+		{"AB\nCC\nA 2+\nB +\n", 4, 3},
+		{"AB\nCC\nA 2+\nB 1 2\n", 4, 1},
+		{"Aé\n", 1, 2},
+		{strings.Repeat("A", MaxSize+1) + "\n", 1, 1},
+	}
+	for _, test := range tests {
+		_, err := Parse(strings.NewReader(test.text))
+		var perr ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("Parse(%q) returned %v, expected a ParseError", test.text, err)
+			continue
+		}
+		if perr.Line != test.line || perr.Col != test.col {
+			t.Errorf("Parse(%q) reported %v, expected line %v, column %v", test.text, perr, test.line, test.col)
+		}
+	}
+}
+
+func TestParseNothingOp(t *testing.T) {
+	p, err := Parse(strings.NewReader("AB\nCD\nA 1=\nB 2\nC 2\nD 1=\n"))
+	if err != nil {
+		t.Fatalf("Parse failed with error: %v", err)
+	}
+	for _, r := range p.GetRegions() {
+		if r.GetOp() != Nothing {
+			t.Errorf("Parsed region with op %v, expected %v", r.GetOp(), Nothing)
+		}
+	}
+}
+
+func TestParseValidates(t *testing.T) {
+	_, err := Parse(strings.NewReader("AB\nBA\nA 2+\nB 4+\n"))
+	var verr ValidationError
+	if !errors.As(err, &verr) {
+		t.Errorf("Parse returned %v, expected a ValidationError", err)
+	}
+}
+
+func compareRegions(t *testing.T, r, exp []Region) {
+	t.Helper()
+	if len(r) != len(exp) {
+		t.Fatalf("Found %v regions, expected %v", len(r), len(exp))
+	}
+	for _, e := range exp {
+		isFound := false
+		for _, region := range r {
+			if region.op == e.op && region.result == e.result && sameIndices(region.indices, e.indices) {
+				isFound = true
+				break
+			}
+		}
+		if !isFound {
+			t.Errorf("Missing region %v", e)
+		}
+	}
+}
+
+func sameIndices(a, b IndexSet) bool {
+	if a.Len() != b.Len() {
+		return false
+	}
+	for idx := range a {
+		if !b.Contains(idx) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,282 @@
+package kenken
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationError lists every structural defect found in a puzzle. Each
+// defect is one of the error types below, and regions are identified by their
+// position in GetRegions.
+type ValidationError struct {
+	Defects []error
+}
+
+func (e ValidationError) Error() string {
+	msgs := make([]string, len(e.Defects))
+	for i, d := range e.Defects {
+		msgs[i] = d.Error()
+	}
+	return fmt.Sprintf("Puzzle has %v defect(s): %v", len(e.Defects), strings.Join(msgs, "; "))
+}
+
+func (e ValidationError) Unwrap() []error {
+	return e.Defects
+}
+
+// UncoveredCellError reports a cell that does not belong to any region.
+type UncoveredCellError struct {
+	Index Index
+}
+
+func (e UncoveredCellError) Error() string {
+	return fmt.Sprintf("Cell %v is not in any region", e.Index)
+}
+
+// OverlappingCellError reports a cell claimed by more than one region.
+type OverlappingCellError struct {
+	Index   Index
+	Regions []int
+}
+
+func (e OverlappingCellError) Error() string {
+	return fmt.Sprintf("Cell %v is in more than one region: %v", e.Index, e.Regions)
+}
+
+// OutOfBoundsError reports a region cell that lies outside of the puzzle.
+type OutOfBoundsError struct {
+	Region int
+	Index  Index
+	Size   uint8
+}
+
+func (e OutOfBoundsError) Error() string {
+	return fmt.Sprintf("Region %v has cell %v outside of the %vx%v puzzle", e.Region, e.Index, e.Size, e.Size)
+}
+
+// NonContiguousRegionError reports a region whose cells are not all connected
+// through shared edges.
+type NonContiguousRegionError struct {
+	Region int
+}
+
+func (e NonContiguousRegionError) Error() string {
+	return fmt.Sprintf("Region %v is not contiguous", e.Region)
+}
+
+// RegionSizeError reports a region with a number of cells its operation
+// doesn't support.
+type RegionSizeError struct {
+	Region   int
+	Op       Operation
+	NumCells int
+}
+
+func (e RegionSizeError) Error() string {
+	return fmt.Sprintf("Region %v has %v cell(s), which is not supported for %v", e.Region, e.NumCells, e.Op)
+}
+
+// UnknownOperationError reports a region with an invalid operation.
+type UnknownOperationError struct {
+	Region int
+	Op     Operation
+}
+
+func (e UnknownOperationError) Error() string {
+	return fmt.Sprintf("Region %v has unknown operation %d", e.Region, uint8(e.Op))
+}
+
+// ImpossibleResultError reports a region whose result is zero or cannot be
+// reached with the values allowed in the puzzle.
+type ImpossibleResultError struct {
+	Region int
+	Op     Operation
+	Result uint
+}
+
+func (e ImpossibleResultError) Error() string {
+	if e.Result == 0 {
+		return fmt.Sprintf("Region %v has a result of zero", e.Region)
+	}
+	return fmt.Sprintf("Region %v cannot reach %v with %v", e.Region, e.Result, e.Op)
+}
+
+// DuplicateValueError reports a value placed twice in the same row or
+// column.
+type DuplicateValueError struct {
+	Value         uint8
+	First, Second Index
+}
+
+func (e DuplicateValueError) Error() string {
+	return fmt.Sprintf("Value %v is at both %v and %v", e.Value, e.First, e.Second)
+}
+
+// BrokenRegionError reports a region whose values can't satisfy it.
+type BrokenRegionError struct {
+	Region int
+}
+
+func (e BrokenRegionError) Error() string {
+	return fmt.Sprintf("Region %v cannot be completed with its values", e.Region)
+}
+
+// Validate checks the puzzle's regions for structural defects. It returns nil
+// or a ValidationError listing every defect found.
+func (p *Puzzle) Validate() error {
+	defects := make([]error, 0)
+	owners := make(map[Index][]int)
+	for i := range p.regions {
+		r := &p.regions[i]
+		isInBounds := true
+		for _, idx := range r.indices.SortedSlice() {
+			if idx.X >= p.size || idx.Y >= p.size {
+				defects = append(defects, OutOfBoundsError{i, idx, p.size})
+				isInBounds = false
+				continue
+			}
+			owners[idx] = append(owners[idx], i)
+		}
+		if err := p.validateRegionShape(i); err != nil {
+			defects = append(defects, err)
+			continue
+		}
+		if r.result == 0 {
+			defects = append(defects, ImpossibleResultError{i, r.op, r.result})
+		} else if isInBounds && !r.forEachAssignment(p.combos(), p.size, nil, func([]Index, []uint8) bool { return true }) {
+			defects = append(defects, ImpossibleResultError{i, r.op, r.result})
+		}
+	}
+	for y := uint8(0); y < p.size; y++ {
+		for x := uint8(0); x < p.size; x++ {
+			idx := Index{x, y}
+			switch regions := owners[idx]; {
+			case len(regions) == 0:
+				defects = append(defects, UncoveredCellError{idx})
+			case len(regions) > 1:
+				defects = append(defects, OverlappingCellError{idx, regions})
+			}
+		}
+	}
+	if len(defects) > 0 {
+		return ValidationError{defects}
+	}
+	return nil
+}
+
+func (p *Puzzle) validateRegionShape(i int) error {
+	r := &p.regions[i]
+	n := r.indices.Len()
+	switch r.op {
+	case Sum, Mul:
+		if n < 1 {
+			return RegionSizeError{i, r.op, n}
+		}
+	case Sub, Div:
+		if n < 2 {
+			return RegionSizeError{i, r.op, n}
+		}
+	case Nothing:
+		if n != 1 {
+			return RegionSizeError{i, r.op, n}
+		}
+	default:
+		return UnknownOperationError{i, r.op}
+	}
+	if !r.isContiguous() {
+		return NonContiguousRegionError{i}
+	}
+	return nil
+}
+
+func (r *Region) isContiguous() bool {
+	cells := r.indices.Slice()
+	if len(cells) == 0 {
+		return true
+	}
+	seen := *NewIndexSet()
+	seen.Add(cells[0])
+	stack := []Index{cells[0]}
+	for len(stack) > 0 {
+		idx := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		neighbours := []Index{{idx.X + 1, idx.Y}, {idx.X - 1, idx.Y}, {idx.X, idx.Y + 1}, {idx.X, idx.Y - 1}}
+		for _, n := range neighbours {
+			if r.indices.Contains(n) && !seen.Contains(n) {
+				seen.Add(n)
+				stack = append(stack, n)
+			}
+		}
+	}
+	return seen.Len() == len(cells)
+}
+
+// fill places the values of grid, a solution read from elsewhere, in the
+// puzzle. It returns an error and leaves the puzzle alone if grid is not a
+// solution.
+func (p *Puzzle) fill(grid [][]uint8) error {
+	if err := p.checkSolution(grid); err != nil {
+		return err
+	}
+	for y := range grid {
+		for x, v := range grid[y] {
+			if i := (Index{uint8(x), uint8(y)}); !p.getBox(i).IsValueSet() {
+				p.place(i, v)
+			}
+		}
+	}
+	return nil
+}
+
+// checkSolution returns an error if grid is not a solution that agrees with
+// the values already set in the puzzle.
+func (p *Puzzle) checkSolution(grid [][]uint8) error {
+	for y := range grid {
+		for x, v := range grid[y] {
+			i := Index{uint8(x), uint8(y)}
+			if v == 0 {
+				return fmt.Errorf("no value for %v", i)
+			}
+			if set := p.GetValue(i); set != 0 && set != v {
+				return fmt.Errorf("%v is %v, but %v is already set", i, v, set)
+			}
+		}
+	}
+	if defects := p.gridDefects(grid); len(defects) > 0 {
+		return ValidationError{defects}
+	}
+	return nil
+}
+
+// gridDefects checks the values in grid, indexed [y][x] with 0 for unknown,
+// against the rows, columns and regions of the puzzle. It returns a
+// DuplicateValueError or BrokenRegionError for each problem found.
+func (p *Puzzle) gridDefects(grid [][]uint8) []error {
+	defects := make([]error, 0)
+	for y := range grid {
+		for x, v := range grid[y] {
+			if v == 0 {
+				continue
+			}
+			for k := 0; k < x; k++ {
+				if grid[y][k] == v {
+					defects = append(defects, DuplicateValueError{v, Index{uint8(k), uint8(y)}, Index{uint8(x), uint8(y)}})
+				}
+			}
+			for k := 0; k < y; k++ {
+				if grid[k][x] == v {
+					defects = append(defects, DuplicateValueError{v, Index{uint8(x), uint8(k)}, Index{uint8(x), uint8(y)}})
+				}
+			}
+		}
+	}
+	allowed := func(i Index, v uint8) bool {
+		return grid[i.Y][i.X] == 0 || grid[i.Y][i.X] == v
+	}
+	for ri := range p.regions {
+		if !p.regions[ri].forEachAssignment(p.combos(), p.size, allowed, func([]Index, []uint8) bool { return true }) {
+			defects = append(defects, BrokenRegionError{ri})
+		}
+	}
+	return defects
+}
@@ -0,0 +1,107 @@
+package kenken
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateExamples(t *testing.T) {
+	pzls := []Puzzle{examplePuzzle(), examplePuzzle2()}
+	for i := range pzls {
+		if err := pzls[i].Validate(); err != nil {
+			t.Errorf("Example puzzle %v failed validation: %v", i, err)
+		}
+	}
+}
+
+func TestValidateCoverage(t *testing.T) {
+	_, err := NewPuzzleBuilder(2).
+		AddCage(Sum, 3, Index{0, 0}, Index{1, 0}).
+		AddCage(Sum, 3, Index{1, 0}, Index{1, 1}).
+		Build()
+	defects := checkDefects(t, err, 2)
+	var uncovered UncoveredCellError
+	if !errors.As(defects[1], &uncovered) || uncovered.Index != (Index{0, 1}) {
+		t.Errorf("Expected uncovered cell (0,1), got: %v", defects[1])
+	}
+	var overlap OverlappingCellError
+	if !errors.As(defects[0], &overlap) || overlap.Index != (Index{1, 0}) || len(overlap.Regions) != 2 {
+		t.Errorf("Expected overlapping cell (1,0), got: %v", defects[0])
+	}
+}
+
+func TestValidateRegionShapes(t *testing.T) {
+	_, err := NewPuzzleBuilder(3).
+		AddCage(Sum, 4, Index{0, 0}, Index{2, 0}).
+		AddCage(Nothing, 2, Index{1, 0}, Index{1, 1}).
+		AddCage(Sub, 1, Index{0, 1}).
+		AddCage(Div, 2, Index{2, 1}, Index{2, 2}).
+		AddCage(Operation(9), 5, Index{0, 2}, Index{1, 2}).
+		Build()
+	defects := checkDefects(t, err, 4)
+	var nonContiguous NonContiguousRegionError
+	if !errors.As(defects[0], &nonContiguous) || nonContiguous.Region != 0 {
+		t.Errorf("Expected region 0 to be non-contiguous, got: %v", defects[0])
+	}
+	var size RegionSizeError
+	if !errors.As(defects[1], &size) || size.Region != 1 || size.NumCells != 2 {
+		t.Errorf("Expected region 1 to have the wrong size, got: %v", defects[1])
+	}
+	if !errors.As(defects[2], &size) || size.Region != 2 || size.Op != Sub {
+		t.Errorf("Expected region 2 to have the wrong size, got: %v", defects[2])
+	}
+	var unknown UnknownOperationError
+	if !errors.As(defects[3], &unknown) || unknown.Region != 4 {
+		t.Errorf("Expected region 4 to have an unknown op, got: %v", defects[3])
+	}
+}
+
+func TestValidateResults(t *testing.T) {
+	_, err := NewPuzzleBuilder(3).
+		AddCage(Sum, 2, Index{0, 0}, Index{1, 0}).
+		AddCage(Nothing, 0, Index{2, 0}).
+		AddCage(Mul, 7, Index{0, 1}, Index{0, 2}).
+		AddCage(Nothing, 4, Index{1, 1}).
+		AddCage(Sum, 8, Index{2, 1}, Index{2, 2}, Index{1, 2}).
+		Build()
+	defects := checkDefects(t, err, 4)
+	expected := []ImpossibleResultError{{0, Sum, 2}, {1, Nothing, 0}, {2, Mul, 7}, {3, Nothing, 4}}
+	for i, exp := range expected {
+		var impossible ImpossibleResultError
+		if !errors.As(defects[i], &impossible) || impossible != exp {
+			t.Errorf("Expected %v, got: %v", exp, defects[i])
+		}
+	}
+}
+
+func TestValidateOutOfBounds(t *testing.T) {
+	p := NewPuzzle(1)
+	p.regions = append(p.regions, *NewRegion(Sum, 3, Index{0, 0}, Index{0, 1}))
+	defects := checkDefects(t, p.Validate(), 1)
+	var outOfBounds OutOfBoundsError
+	if !errors.As(defects[0], &outOfBounds) || outOfBounds.Index != (Index{0, 1}) {
+		t.Errorf("Expected (0,1) to be out of bounds, got: %v", defects[0])
+	}
+}
+
+func TestSolveRejectsInvalidPuzzle(t *testing.T) {
+	p := NewPuzzle(2)
+	p.regions = append(p.regions, *NewRegion(Nothing, 1, Index{0, 0}))
+	p.prepare()
+	var verr ValidationError
+	if err := p.Solve(); !errors.As(err, &verr) {
+		t.Errorf("Solve returned %v, expected a ValidationError", err)
+	}
+}
+
+func checkDefects(t *testing.T, err error, n int) []error {
+	t.Helper()
+	var verr ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected a ValidationError, got: %v", err)
+	}
+	if len(verr.Defects) != n {
+		t.Fatalf("Found %v defects, expected %v: %v", len(verr.Defects), n, verr)
+	}
+	return verr.Defects
+}